@@ -0,0 +1,218 @@
+package httpbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Principal is the authenticated entity making a request
+type Principal interface {
+	Identity() string
+}
+
+type SubjectPrincipal string
+
+func (p SubjectPrincipal) Identity() string {
+	return string(p)
+}
+
+// ErrInvalidCredentials is returned by verifiers when the credentials are well
+// formed but do not match. Any other error is handled as an internal error
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	state := requestStateFrom(ctx)
+	if state == nil || state.principal == nil {
+		return nil, false
+	}
+
+	return state.principal, true
+}
+
+func ClaimsFromContext[C any](ctx context.Context) (C, bool) {
+	state := requestStateFrom(ctx)
+	if state == nil {
+		var zero C
+		return zero, false
+	}
+
+	claims, ok := state.claims.(C)
+	return claims, ok
+}
+
+func withPrincipal(r *http.Request, principal Principal, claims any) *http.Request {
+	r, state := withRequestState(r)
+	state.principal = principal
+	state.claims = claims
+
+	return r
+}
+
+func newUnauthorizedError(w http.ResponseWriter, challenge, message string) *Error {
+	w.Header().Set("WWW-Authenticate", challenge)
+
+	return NewError(http.StatusUnauthorized, message)
+}
+
+// verifierError maps errors returned by user provided verifiers, keeping
+// *Error values so verifiers can pick their own status code
+func verifierError(err error) error {
+	var httpErr *Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	return NewError(http.StatusInternalServerError, "Unexpected error occurred",
+		WithInternalError(err),
+		WithLog(),
+	)
+}
+
+// constantTimeEqual compares digests so that neither the content nor the
+// length of the expected value leaks through timing
+func constantTimeEqual(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+type BasicVerifier func(ctx context.Context, username, password string) (Principal, error)
+
+// BasicUsers verifies credentials against a fixed set of username and password
+// pairs. Every entry is compared so the time taken does not reveal valid users
+func BasicUsers(users map[string]string) BasicVerifier {
+	return func(ctx context.Context, username, password string) (Principal, error) {
+		match := false
+
+		for user, pass := range users {
+			userMatch := constantTimeEqual(user, username)
+			passMatch := constantTimeEqual(pass, password)
+
+			if userMatch && passMatch {
+				match = true
+			}
+		}
+
+		if !match {
+			return nil, ErrInvalidCredentials
+		}
+
+		return SubjectPrincipal(username), nil
+	}
+}
+
+func BasicAuthMiddleware(realm string, verify BasicVerifier) Middleware {
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, realm)
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				return newUnauthorizedError(w, challenge, "authentication required")
+			}
+
+			principal, err := verify(r.Context(), username, password)
+			if errors.Is(err, ErrInvalidCredentials) {
+				return newUnauthorizedError(w, challenge, "invalid credentials")
+			}
+			if err != nil {
+				return verifierError(err)
+			}
+
+			return h(w, withPrincipal(r, principal, nil))
+		}
+	}
+}
+
+type TokenVerifier func(ctx context.Context, token string) (Principal, error)
+
+// StaticTokens verifies tokens against a fixed set, such as API keys loaded
+// from configuration
+func StaticTokens(tokens map[string]Principal) TokenVerifier {
+	return func(ctx context.Context, token string) (Principal, error) {
+		var found Principal
+
+		for candidate, principal := range tokens {
+			if constantTimeEqual(candidate, token) {
+				found = principal
+			}
+		}
+
+		if found == nil {
+			return nil, ErrInvalidCredentials
+		}
+
+		return found, nil
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
+
+func bearerChallenge(realm, errorCode, description string) string {
+	if errorCode == "" {
+		return fmt.Sprintf("Bearer realm=%q", realm)
+	}
+
+	return fmt.Sprintf("Bearer realm=%q, error=%q, error_description=%q", realm, errorCode, description)
+}
+
+func BearerAuthMiddleware(realm string, verify TokenVerifier) Middleware {
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			token, ok := bearerToken(r)
+			if !ok {
+				return newUnauthorizedError(w, bearerChallenge(realm, "", ""), "authentication required")
+			}
+
+			principal, err := verify(r.Context(), token)
+			if errors.Is(err, ErrInvalidCredentials) {
+				challenge := bearerChallenge(realm, "invalid_token", "the access token is invalid")
+				return newUnauthorizedError(w, challenge, "invalid credentials")
+			}
+			if err != nil {
+				return verifierError(err)
+			}
+
+			return h(w, withPrincipal(r, principal, nil))
+		}
+	}
+}
+
+// APIKeyMiddleware reads the key from a custom header such as X-API-Key
+func APIKeyMiddleware(header string, verify TokenVerifier) Middleware {
+	challenge := fmt.Sprintf("APIKey header=%q", header)
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			key := r.Header.Get(header)
+			if key == "" {
+				return newUnauthorizedError(w, challenge, "authentication required")
+			}
+
+			principal, err := verify(r.Context(), key)
+			if errors.Is(err, ErrInvalidCredentials) {
+				return newUnauthorizedError(w, challenge, "invalid credentials")
+			}
+			if err != nil {
+				return verifierError(err)
+			}
+
+			return h(w, withPrincipal(r, principal, nil))
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package httpbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func principalEchoHandler() Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			return errors.New("missing principal")
+		}
+
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte(principal.Identity()))
+	}
+}
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	h := principalEchoHandler().WithMiddlewares(BasicAuthMiddleware("admin", BasicUsers(map[string]string{
+		"alice": "secret",
+	})))
+
+	tests := []struct {
+		name         string
+		username     string
+		password     string
+		setAuth      bool
+		expectedCode int
+		expectedBody string
+	}{
+		{"valid credentials", "alice", "secret", true, http.StatusOK, "alice"},
+		{"wrong password", "alice", "wrong", true, http.StatusUnauthorized, `{"code":401,"message":"invalid credentials"}`},
+		{"unknown user", "bob", "secret", true, http.StatusUnauthorized, `{"code":401,"message":"invalid credentials"}`},
+		{"missing header", "", "", false, http.StatusUnauthorized, `{"code":401,"message":"authentication required"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.setAuth {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, tt.expectedBody, rec.Body.String())
+			} else {
+				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+				assert.Equal(t, `Basic realm="admin", charset="UTF-8"`, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestBasicAuthMiddleware_VerifierError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{"internal error", errors.New("database down"), http.StatusInternalServerError},
+		{"httpbox error", NewError(http.StatusForbidden, "account locked"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := principalEchoHandler().WithMiddlewares(BasicAuthMiddleware("admin",
+				func(ctx context.Context, username, password string) (Principal, error) {
+					return nil, tt.err
+				},
+			))
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.SetBasicAuth("alice", "secret")
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestBearerAuthMiddleware(t *testing.T) {
+	h := principalEchoHandler().WithMiddlewares(BearerAuthMiddleware("api", StaticTokens(map[string]Principal{
+		"token-1": SubjectPrincipal("service-a"),
+	})))
+
+	tests := []struct {
+		name              string
+		authorization     string
+		expectedCode      int
+		expectedChallenge string
+	}{
+		{"valid token", "Bearer token-1", http.StatusOK, ""},
+		{"case insensitive scheme", "bearer token-1", http.StatusOK, ""},
+		{"invalid token", "Bearer token-2", http.StatusUnauthorized, `Bearer realm="api", error="invalid_token", error_description="the access token is invalid"`},
+		{"wrong scheme", "Basic token-1", http.StatusUnauthorized, `Bearer realm="api"`},
+		{"missing header", "", http.StatusUnauthorized, `Bearer realm="api"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedChallenge, rec.Header().Get("WWW-Authenticate"))
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, "service-a", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	h := principalEchoHandler().WithMiddlewares(APIKeyMiddleware("X-API-Key", StaticTokens(map[string]Principal{
+		"key-1": SubjectPrincipal("partner"),
+	})))
+
+	valid := httptest.NewRequest(http.MethodGet, "/test", nil)
+	valid.Header.Set("X-API-Key", "key-1")
+	validRec := httptest.NewRecorder()
+	h.ServeHTTP(validRec, valid)
+
+	assert.Equal(t, http.StatusOK, validRec.Code)
+	assert.Equal(t, "partner", validRec.Body.String())
+
+	missingRec := httptest.NewRecorder()
+	h.ServeHTTP(missingRec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, missingRec.Code)
+	assert.Equal(t, `APIKey header="X-API-Key"`, missingRec.Header().Get("WWW-Authenticate"))
+}
+
+func TestPrincipalFromContext_Missing(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+
+	require.False(t, ok)
+}
@@ -0,0 +1,242 @@
+package httpbox
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Principals opt into each kind of authorization check by implementing the
+// matching interface. A principal that does not implement one of them is
+// treated as holding none of the corresponding grants
+type RoleHolder interface {
+	HasRole(role string) bool
+}
+
+type ScopeHolder interface {
+	HasScope(scope string) bool
+}
+
+type PermissionHolder interface {
+	HasPermission(permission string) bool
+}
+
+type StaticPrincipal struct {
+	Subject     string
+	Roles       []string
+	Scopes      []string
+	Permissions []string
+}
+
+func (p StaticPrincipal) Identity() string {
+	return p.Subject
+}
+
+func (p StaticPrincipal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+func (p StaticPrincipal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+func (p StaticPrincipal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+// Policy decides whether the principal may perform the request, returning an
+// error to deny it. Custom policies usually return errors built with Forbidden
+type Policy func(r *http.Request, principal Principal) error
+
+type ForbiddenDetails struct {
+	Kind    string   `json:"kind,omitempty"`
+	Missing []string `json:"missing,omitempty"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+func Forbidden(details ForbiddenDetails) *Error {
+	return NewError(http.StatusForbidden, "permission denied", WithDetails(details))
+}
+
+func requireAll(kind string, values []string, has func(Principal, string) bool) Policy {
+	return func(r *http.Request, principal Principal) error {
+		var missing []string
+
+		for _, value := range values {
+			if !has(principal, value) {
+				missing = append(missing, value)
+			}
+		}
+
+		if len(missing) > 0 {
+			return Forbidden(ForbiddenDetails{Kind: kind, Missing: missing})
+		}
+
+		return nil
+	}
+}
+
+func requireAny(kind string, values []string, has func(Principal, string) bool) Policy {
+	return func(r *http.Request, principal Principal) error {
+		if slices.ContainsFunc(values, func(value string) bool { return has(principal, value) }) {
+			return nil
+		}
+
+		return Forbidden(ForbiddenDetails{Kind: kind, Missing: values, Reason: "one of the listed values is required"})
+	}
+}
+
+func hasRole(principal Principal, role string) bool {
+	holder, ok := principal.(RoleHolder)
+	return ok && holder.HasRole(role)
+}
+
+func hasScope(principal Principal, scope string) bool {
+	holder, ok := principal.(ScopeHolder)
+	return ok && holder.HasScope(scope)
+}
+
+func hasPermission(principal Principal, permission string) bool {
+	holder, ok := principal.(PermissionHolder)
+	return ok && holder.HasPermission(permission)
+}
+
+func RequireRoles(roles ...string) Policy {
+	return requireAll("role", roles, hasRole)
+}
+
+func RequireAnyRole(roles ...string) Policy {
+	return requireAny("role", roles, hasRole)
+}
+
+func RequireScopes(scopes ...string) Policy {
+	return requireAll("scope", scopes, hasScope)
+}
+
+func RequireAnyScope(scopes ...string) Policy {
+	return requireAny("scope", scopes, hasScope)
+}
+
+func RequirePermissions(permissions ...string) Policy {
+	return requireAll("permission", permissions, hasPermission)
+}
+
+func RequireAnyPermission(permissions ...string) Policy {
+	return requireAny("permission", permissions, hasPermission)
+}
+
+func AllOf(policies ...Policy) Policy {
+	return func(r *http.Request, principal Principal) error {
+		for _, policy := range policies {
+			if err := policy(r, principal); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// AnyOf allows the request when at least one policy does, otherwise the error
+// of the first policy is returned
+func AnyOf(policies ...Policy) Policy {
+	return func(r *http.Request, principal Principal) error {
+		var first error
+
+		for _, policy := range policies {
+			err := policy(r, principal)
+			if err == nil {
+				return nil
+			}
+
+			if first == nil {
+				first = err
+			}
+		}
+
+		return first
+	}
+}
+
+// Authenticated only requires a principal to be present
+func Authenticated() Policy {
+	return func(r *http.Request, principal Principal) error {
+		return nil
+	}
+}
+
+// defaultChallenge is sent with 401 errors when no authentication middleware
+// set a challenge of its own
+var defaultChallenge = bearerChallenge("", "", "")
+
+func authorize(w http.ResponseWriter, r *http.Request, policy Policy) error {
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		challenge := w.Header().Get("WWW-Authenticate")
+		if challenge == "" {
+			challenge = defaultChallenge
+		}
+
+		return newUnauthorizedError(w, challenge, "authentication required")
+	}
+
+	return policy(r, principal)
+}
+
+// AuthorizeMiddleware enforces every policy on the wrapped handler. It must run
+// after an authentication middleware
+func AuthorizeMiddleware(policies ...Policy) Middleware {
+	policy := AllOf(policies...)
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if err := authorize(w, r, policy); err != nil {
+				return err
+			}
+
+			return h(w, r)
+		}
+	}
+}
+
+type routePolicyConfig struct {
+	fallback Policy
+}
+
+type RoutePolicyOption func(*routePolicyConfig)
+
+// WithFallbackPolicy sets the policy of routes missing from the map. By default
+// they are denied, so forgetting to declare a route does not expose it
+func WithFallbackPolicy(policy Policy) RoutePolicyOption {
+	return func(cfg *routePolicyConfig) {
+		cfg.fallback = policy
+	}
+}
+
+// RoutePolicyMiddleware declares the policies of every route in one place,
+// keyed by ServeMux pattern
+func RoutePolicyMiddleware(policies map[string]Policy, opts ...RoutePolicyOption) Middleware {
+	cfg := &routePolicyConfig{
+		fallback: func(r *http.Request, principal Principal) error {
+			return Forbidden(ForbiddenDetails{Reason: "no policy declared for this route"})
+		},
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			policy, ok := policies[routePattern(r)]
+			if !ok {
+				policy = cfg.fallback
+			}
+
+			if err := authorize(w, r, policy); err != nil {
+				return err
+			}
+
+			return h(w, r)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package httpbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withTestPrincipal(principal Principal) Middleware {
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if principal == nil {
+				return h(w, r)
+			}
+			return h(w, withPrincipal(r, principal, nil))
+		}
+	}
+}
+
+func okHandler() Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+}
+
+func TestAuthorizeMiddleware(t *testing.T) {
+	editor := StaticPrincipal{
+		Subject:     "alice",
+		Roles:       []string{"editor"},
+		Scopes:      []string{"articles:read", "articles:write"},
+		Permissions: []string{"publish"},
+	}
+
+	tests := []struct {
+		name            string
+		principal       Principal
+		policies        []Policy
+		expectedCode    int
+		expectedDetails *ForbiddenDetails
+	}{
+		{"role granted", editor, []Policy{RequireRoles("editor")}, http.StatusOK, nil},
+		{"role missing", editor, []Policy{RequireRoles("editor", "admin")}, http.StatusForbidden,
+			&ForbiddenDetails{Kind: "role", Missing: []string{"admin"}}},
+		{"any role", editor, []Policy{RequireAnyRole("admin", "editor")}, http.StatusOK, nil},
+		{"scopes granted", editor, []Policy{RequireScopes("articles:read", "articles:write")}, http.StatusOK, nil},
+		{"scope missing", editor, []Policy{RequireScopes("users:read")}, http.StatusForbidden,
+			&ForbiddenDetails{Kind: "scope", Missing: []string{"users:read"}}},
+		{"any scope missing", editor, []Policy{RequireAnyScope("users:read", "users:write")}, http.StatusForbidden,
+			&ForbiddenDetails{Kind: "scope", Missing: []string{"users:read", "users:write"}, Reason: "one of the listed values is required"}},
+		{"permission granted", editor, []Policy{RequirePermissions("publish")}, http.StatusOK, nil},
+		{"any permission", editor, []Policy{RequireAnyPermission("delete", "publish")}, http.StatusOK, nil},
+		{"all policies must pass", editor, []Policy{RequireRoles("editor"), RequirePermissions("delete")}, http.StatusForbidden,
+			&ForbiddenDetails{Kind: "permission", Missing: []string{"delete"}}},
+		{"any of policies", editor, []Policy{AnyOf(RequireRoles("admin"), RequireScopes("articles:write"))}, http.StatusOK, nil},
+		{"principal without roles", SubjectPrincipal("bob"), []Policy{RequireRoles("editor")}, http.StatusForbidden,
+			&ForbiddenDetails{Kind: "role", Missing: []string{"editor"}}},
+		{"authenticated", SubjectPrincipal("bob"), []Policy{Authenticated()}, http.StatusOK, nil},
+		{"no principal", nil, []Policy{Authenticated()}, http.StatusUnauthorized, nil},
+		{"custom policy", editor, []Policy{func(r *http.Request, p Principal) error {
+			if r.URL.Query().Get("owner") != p.Identity() {
+				return Forbidden(ForbiddenDetails{Reason: "only the owner can edit"})
+			}
+			return nil
+		}}, http.StatusForbidden, &ForbiddenDetails{Reason: "only the owner can edit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := okHandler().WithMiddlewares(withTestPrincipal(tt.principal), AuthorizeMiddleware(tt.policies...))
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test?owner=bob", nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+
+			if tt.expectedDetails != nil {
+				var body struct {
+					Details ForbiddenDetails `json:"details"`
+				}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, *tt.expectedDetails, body.Details)
+			}
+		})
+	}
+}
+
+func TestAuthorizeMiddleware_Challenge(t *testing.T) {
+	h := okHandler().WithMiddlewares(AuthorizeMiddleware(Authenticated()))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer realm=""`, rec.Header().Get("WWW-Authenticate"))
+
+	// The challenge of a preceding authentication middleware is kept
+	h = okHandler().WithMiddlewares(headerSetter("WWW-Authenticate", `Basic realm="admin"`), AuthorizeMiddleware(Authenticated()))
+	rec = httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Basic realm="admin"`, rec.Header().Get("WWW-Authenticate"))
+}
+
+func headerSetter(name, value string) Middleware {
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set(name, value)
+			return h(w, r)
+		}
+	}
+}
+
+func TestRoutePolicyMiddleware(t *testing.T) {
+	policies := RoutePolicyMiddleware(map[string]Policy{
+		"GET /articles":    Authenticated(),
+		"DELETE /articles": RequireRoles("admin"),
+	})
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{"GET /articles", "DELETE /articles", "POST /articles"} {
+		mux.Handle(pattern, okHandler().WithMiddlewares(policies))
+	}
+
+	h := AdaptHandler(mux).WithMiddlewares(withTestPrincipal(StaticPrincipal{Subject: "alice"}))
+
+	tests := []struct {
+		method       string
+		expectedCode int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodDelete, http.StatusForbidden},
+		{http.MethodPost, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, "/articles", nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestRoutePolicyMiddleware_Fallback(t *testing.T) {
+	h := okHandler().WithMiddlewares(
+		withTestPrincipal(SubjectPrincipal("alice")),
+		RoutePolicyMiddleware(nil, WithFallbackPolicy(Authenticated())),
+	)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
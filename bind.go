@@ -0,0 +1,196 @@
+package httpbox
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// boundField is a struct field bound to a named value, such as a CSV column or
+// a form field
+type boundField struct {
+	name   string
+	index  []int
+	format string
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	durationType      = reflect.TypeFor[time.Duration]()
+	textUnmarshalType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	textMarshalType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// boundFields lists the exported fields of struct type t, named after their tag
+// or their Go name. Fields tagged "-" are skipped and embedded structs are
+// flattened. Time fields are parsed and formatted with the layout of their
+// format tag, RFC 3339 by default
+func boundFields(t reflect.Type, tag string) []boundField {
+	var fields []boundField
+
+	for i := range t.NumField() {
+		sf := t.Field(i)
+
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs are flattened even when their type is unexported, as
+		// encoding/json does
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && name == "" {
+			for _, field := range boundFields(sf.Type, tag) {
+				field.index = append([]int{i}, field.index...)
+				fields = append(fields, field)
+			}
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		format := sf.Tag.Get("format")
+		if format == "" {
+			format = time.RFC3339
+		}
+
+		fields = append(fields, boundField{name: name, index: sf.Index, format: format})
+	}
+
+	return fields
+}
+
+// bind converts the value of p to the type of v, following the rules of the
+// Param methods
+func (p Param) bind(v reflect.Value, format string) error {
+	if v.Kind() == reflect.Pointer {
+		if p.value == "" {
+			return nil
+		}
+
+		ptr := reflect.New(v.Type().Elem())
+		if err := p.bind(ptr.Elem(), format); err != nil {
+			return err
+		}
+
+		v.Set(ptr)
+		return nil
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalType) && v.Type() != timeType {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(p.value)); err != nil {
+			return p.newError("is invalid")
+		}
+		return nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		t, err := p.Time(format)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+
+	case durationType:
+		d, err := p.Duration()
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(p.String())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := p.Int()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(int64(n)) {
+			return p.newError("is out of range")
+		}
+		v.SetInt(int64(n))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := p.Int()
+		if err != nil {
+			return err
+		}
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return p.newError("is out of range")
+		}
+		v.SetUint(uint64(n))
+
+	case reflect.Float32, reflect.Float64:
+		f, err := p.Float()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	case reflect.Bool:
+		b, err := p.Bool()
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	default:
+		return fmt.Errorf("httpbox: unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
+
+// formatValue is the counterpart of Param.bind, used to write values
+func formatValue(v reflect.Value, format string) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch v.Type() {
+	case timeType:
+		return v.Interface().(time.Time).Format(format)
+	case durationType:
+		return time.Duration(v.Int()).String()
+	}
+
+	if v.Type().Implements(textMarshalType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(text)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	}
+
+	return fmt.Sprint(v.Interface())
+}
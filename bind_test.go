@@ -0,0 +1,147 @@
+package httpbox
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindEmbedded struct {
+	Note string `csv:"note"`
+}
+
+type bindTarget struct {
+	bindEmbedded
+	Name     string        `csv:"name"`
+	Age      int8          `csv:"age"`
+	Count    uint          `csv:"count"`
+	Score    float64       `csv:"score"`
+	Active   bool          `csv:"active"`
+	Born     time.Time     `csv:"born" format:"2006-01-02"`
+	Timeout  time.Duration `csv:"timeout"`
+	IP       net.IP        `csv:"ip"`
+	Nickname *string       `csv:"nickname"`
+	Ignored  string        `csv:"-"`
+	Untagged string
+	hidden   string
+}
+
+func TestBoundFields(t *testing.T) {
+	var names []string
+	for _, field := range boundFields(reflect.TypeFor[bindTarget](), "csv") {
+		names = append(names, field.name)
+	}
+
+	assert.Equal(t, []string{"note", "name", "age", "count", "score", "active", "born", "timeout", "ip", "nickname", "Untagged"}, names)
+}
+
+func TestParam_Bind(t *testing.T) {
+	var target bindTarget
+	v := reflect.ValueOf(&target).Elem()
+
+	values := map[string]string{
+		"note":     "embedded",
+		"name":     "John",
+		"age":      "42",
+		"count":    "7",
+		"score":    "3.5",
+		"active":   "true",
+		"born":     "1990-05-17",
+		"timeout":  "1m30s",
+		"ip":       "10.0.0.1",
+		"nickname": "Johnny",
+	}
+
+	for _, field := range boundFields(v.Type(), "csv") {
+		value, ok := values[field.name]
+		if !ok {
+			continue
+		}
+
+		p := Param{from: fromQuery, name: field.name, value: value}
+		require.NoError(t, p.bind(v.FieldByIndex(field.index), field.format), field.name)
+	}
+
+	assert.Equal(t, "embedded", target.Note)
+	assert.Equal(t, "John", target.Name)
+	assert.Equal(t, int8(42), target.Age)
+	assert.Equal(t, uint(7), target.Count)
+	assert.Equal(t, 3.5, target.Score)
+	assert.True(t, target.Active)
+	assert.Equal(t, time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), target.Born)
+	assert.Equal(t, 90*time.Second, target.Timeout)
+	assert.Equal(t, "10.0.0.1", target.IP.String())
+	require.NotNil(t, target.Nickname)
+	assert.Equal(t, "Johnny", *target.Nickname)
+}
+
+func TestParam_BindErrors(t *testing.T) {
+	tests := []struct {
+		name            string
+		target          any
+		value           string
+		expectedMessage string
+	}{
+		{"invalid int", new(int), "abc", `parameter "p" from URL query string must be an integer`},
+		{"int overflow", new(int8), "300", `parameter "p" from URL query string is out of range`},
+		{"negative uint", new(uint), "-1", `parameter "p" from URL query string is out of range`},
+		{"invalid bool", new(bool), "maybe", `parameter "p" from URL query string must be a boolean. Example values: true, false, 1, 0`},
+		{"invalid text", new(net.IP), "999.0.0.1", `parameter "p" from URL query string is invalid`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Param{from: fromQuery, name: "p", value: tt.value}
+
+			err := p.bind(reflect.ValueOf(tt.target).Elem(), time.RFC3339)
+
+			var httpErr *Error
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+			assert.Equal(t, tt.expectedMessage, httpErr.Message)
+		})
+	}
+}
+
+func TestParam_BindUnsupported(t *testing.T) {
+	p := Param{from: fromQuery, name: "p", value: "x"}
+
+	err := p.bind(reflect.ValueOf(new(map[string]string)).Elem(), "")
+
+	var httpErr *Error
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &httpErr))
+}
+
+func TestFormatValue(t *testing.T) {
+	nickname := "Johnny"
+
+	tests := []struct {
+		value    any
+		format   string
+		expected string
+	}{
+		{"text", "", "text"},
+		{-42, "", "-42"},
+		{uint16(7), "", "7"},
+		{float32(0.1), "", "0.1"},
+		{true, "", "true"},
+		{time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), "2006-01-02", "1990-05-17"},
+		{90 * time.Second, "", "1m30s"},
+		{net.ParseIP("10.0.0.1"), "", "10.0.0.1"},
+		{&nickname, "", "Johnny"},
+		{(*string)(nil), "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, formatValue(reflect.ValueOf(tt.value), tt.format))
+		})
+	}
+}
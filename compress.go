@@ -0,0 +1,377 @@
+package httpbox
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Compressor is a compressing writer that can be reused through Reset, such as
+// *gzip.Writer and *zlib.Writer
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+const DefaultCompressionMinSize = 1024
+
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/problem+json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+type compressionEncoder struct {
+	encoding      string
+	newCompressor func(w io.Writer) Compressor
+}
+
+type compressionConfig struct {
+	level        int
+	minSize      int
+	contentTypes []string
+	encoders     []compressionEncoder
+}
+
+type CompressionOption func(*compressionConfig)
+
+// WithCompressionLevel sets the level of the gzip and deflate encoders, from
+// flate.HuffmanOnly to flate.BestCompression
+func WithCompressionLevel(level int) CompressionOption {
+	return func(cfg *compressionConfig) {
+		cfg.level = level
+	}
+}
+
+// WithCompressionMinSize sets the size under which responses are sent
+// uncompressed, as compressing them is not worth the overhead
+func WithCompressionMinSize(size int) CompressionOption {
+	return func(cfg *compressionConfig) {
+		cfg.minSize = size
+	}
+}
+
+// WithCompressionContentTypes replaces the media types eligible for
+// compression. Types such as "text/*" match any subtype
+func WithCompressionContentTypes(contentTypes ...string) CompressionOption {
+	return func(cfg *compressionConfig) {
+		cfg.contentTypes = contentTypes
+	}
+}
+
+// WithCompressionEncoder registers an additional content coding, such as br or
+// zstd. Registered encoders are preferred over gzip and deflate when the client
+// accepts them with the same quality
+func WithCompressionEncoder(encoding string, newCompressor func(w io.Writer) Compressor) CompressionOption {
+	return func(cfg *compressionConfig) {
+		cfg.encoders = append(cfg.encoders, compressionEncoder{strings.ToLower(encoding), newCompressor})
+	}
+}
+
+// CompressionMiddleware compresses response bodies with the best encoding
+// accepted by the client. The start of the body is buffered until it reaches
+// the minimum size, so that small responses are sent as is. Flushing the
+// response ends the buffering, which keeps streaming responses working
+func CompressionMiddleware(opts ...CompressionOption) Middleware {
+	cfg := &compressionConfig{
+		level:        gzip.DefaultCompression,
+		minSize:      DefaultCompressionMinSize,
+		contentTypes: defaultCompressibleTypes,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if _, err := gzip.NewWriterLevel(io.Discard, cfg.level); err != nil {
+		panic(fmt.Sprintf("httpbox: invalid compression level %d", cfg.level))
+	}
+
+	encoders := append(cfg.encoders,
+		compressionEncoder{"gzip", func(w io.Writer) Compressor {
+			gw, _ := gzip.NewWriterLevel(w, cfg.level)
+			return gw
+		}},
+		// The deflate coding is the zlib format, not raw DEFLATE
+		compressionEncoder{"deflate", func(w io.Writer) Compressor {
+			zw, _ := zlib.NewWriterLevel(w, cfg.level)
+			return zw
+		}},
+	)
+
+	supported := make([]string, len(encoders))
+	pools := make(map[string]*sync.Pool, len(encoders))
+
+	for i, encoder := range encoders {
+		supported[i] = encoder.encoding
+		pools[encoder.encoding] = &sync.Pool{
+			New: func() any { return encoder.newCompressor(io.Discard) },
+		}
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), supported)
+			if encoding == "" || r.Method == http.MethodHead {
+				return h(w, r)
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				cfg:            cfg,
+				encoding:       encoding,
+				pool:           pools[encoding],
+			}
+
+			err := h(cw, r)
+
+			if closeErr := cw.close(); err == nil {
+				err = closeErr
+			}
+
+			return err
+		}
+	}
+}
+
+// negotiateEncoding returns the supported encoding with the highest quality in
+// the Accept-Encoding header, or an empty string to send the body as is
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+
+	for part := range strings.SplitSeq(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0
+
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+
+		qualities[name] = quality
+	}
+
+	best, bestQuality := "", 0.0
+
+	for _, encoding := range supported {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = qualities["*"]
+		}
+
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+
+	return best
+}
+
+func matchContentType(contentType string, patterns []string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      *compressionConfig
+	encoding string
+	pool     *sync.Pool
+
+	statusCode  int
+	wroteHeader bool
+	// committed is set once the status was sent and the body is either
+	// compressed or written as is
+	committed  bool
+	buf        []byte
+	compressor Compressor
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+
+	if statusCode < 200 {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	cw.statusCode = statusCode
+	cw.wroteHeader = true
+
+	if !cw.eligible() {
+		cw.commit(false)
+		return
+	}
+
+	// Without a content type, the body is buffered to sniff it before compressing
+	length, err := strconv.Atoi(cw.Header().Get("Content-Length"))
+	if err == nil && (length < cw.cfg.minSize || cw.Header().Get("Content-Type") != "") {
+		cw.commit(length >= cw.cfg.minSize)
+	}
+}
+
+// eligible reports whether the response could be compressed, before looking
+// at its body
+func (cw *compressWriter) eligible() bool {
+	switch cw.statusCode {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	return contentType == "" || matchContentType(contentType, cw.cfg.contentTypes)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.committed {
+		if cw.compressor != nil {
+			return cw.compressor.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+
+	if len(cw.buf) >= cw.cfg.minSize {
+		if err := cw.commit(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// commit sends the status and the buffered body, compressing it if requested
+// and the content type allows it
+func (cw *compressWriter) commit(compress bool) error {
+	cw.committed = true
+	header := cw.Header()
+
+	if compress && header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// net/http would otherwise sniff the compressed bytes
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if compress && cw.eligible() {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+
+		// The compressed representation differs from the original one
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		cw.compressor = cw.pool.Get().(Compressor)
+		cw.compressor.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+
+	var err error
+	if cw.compressor != nil {
+		_, err = cw.compressor.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+func (cw *compressWriter) FlushError() error {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.committed {
+		if err := cw.commit(true); err != nil {
+			return err
+		}
+	}
+
+	if cw.compressor != nil {
+		if err := cw.compressor.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Flush() {
+	cw.FlushError()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close writes what is left of the response once the handler returned
+func (cw *compressWriter) close() error {
+	if cw.wroteHeader && !cw.committed {
+		if err := cw.commit(false); err != nil {
+			return err
+		}
+	}
+
+	if cw.compressor == nil {
+		return nil
+	}
+
+	err := cw.compressor.Close()
+
+	cw.compressor.Reset(io.Discard)
+	cw.pool.Put(cw.compressor)
+	cw.compressor = nil
+
+	return err
+}
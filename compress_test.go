@@ -0,0 +1,207 @@
+package httpbox
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gunzip(t *testing.T, r io.Reader) string {
+	t.Helper()
+
+	gr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(gr)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func serveCompressed(h Handler, acceptEncoding string, opts ...CompressionOption) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	rec := httptest.NewRecorder()
+	h.WithMiddlewares(CompressionMiddleware(opts...)).ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestCompressionMiddleware_Compresses(t *testing.T) {
+	body := strings.Repeat(`{"message":"hello"}`, 100)
+
+	rec := serveCompressed(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("ETag", `"v1"`)
+		return WriteBytes(w, http.StatusCreated, "application/json", []byte(body))
+	}, "gzip")
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.Equal(t, `W/"v1"`, rec.Header().Get("ETag"))
+	assert.Equal(t, body, gunzip(t, rec.Body))
+}
+
+func TestCompressionMiddleware_Skipped(t *testing.T) {
+	large := strings.Repeat("a", 2048)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		handler        Handler
+	}{
+		{
+			name:           "not accepted",
+			acceptEncoding: "",
+			handler:        textHandler(large),
+		},
+		{
+			name:           "below threshold",
+			acceptEncoding: "gzip",
+			handler:        textHandler("small"),
+		},
+		{
+			name:           "content type not compressible",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return WriteBytes(w, http.StatusOK, "image/png", []byte(large))
+			},
+		},
+		{
+			name:           "already encoded",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("Content-Encoding", "br")
+				return WriteBytes(w, http.StatusOK, "text/plain", []byte(large))
+			},
+		},
+		{
+			name:           "no content",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.WriteHeader(http.StatusNoContent)
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveCompressed(tt.handler, tt.acceptEncoding)
+
+			assert.NotEqual(t, "gzip", rec.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestCompressionMiddleware_SniffsContentType(t *testing.T) {
+	body := "<html><body>" + strings.Repeat("hello ", 500) + "</body></html>"
+
+	rec := serveCompressed(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := io.WriteString(w, body)
+		return err
+	}, "gzip")
+
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, body, gunzip(t, rec.Body))
+}
+
+func TestCompressionMiddleware_ErrorNotCompressed(t *testing.T) {
+	rec := serveCompressed(func(w http.ResponseWriter, r *http.Request) error {
+		return NewError(http.StatusNotFound, "not found")
+	}, "gzip")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, `{"code":404,"message":"not found"}`, rec.Body.String())
+}
+
+func TestCompressionMiddleware_Flush(t *testing.T) {
+	rec := serveCompressed(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		io.WriteString(w, "data: first\n\n")
+		require.NoError(t, http.NewResponseController(w).Flush())
+
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+		io.WriteString(w, "data: second\n\n")
+		return nil
+	}, "gzip")
+
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "data: first\n\ndata: second\n\n", gunzip(t, rec.Body))
+}
+
+func TestCompressionMiddleware_Deflate(t *testing.T) {
+	body := strings.Repeat("hello ", 500)
+
+	rec := serveCompressed(textHandler(body), "deflate")
+
+	assert.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+
+	zr, err := zlib.NewReader(rec.Body)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(data))
+}
+
+func TestCompressionMiddleware_CustomEncoder(t *testing.T) {
+	body := strings.Repeat("hello ", 500)
+
+	rec := serveCompressed(textHandler(body), "gzip, x-test",
+		WithCompressionEncoder("x-test", func(w io.Writer) Compressor {
+			return gzip.NewWriter(w)
+		}),
+	)
+
+	assert.Equal(t, "x-test", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, gunzip(t, rec.Body))
+}
+
+func TestCompressionMiddleware_InvalidLevel(t *testing.T) {
+	assert.Panics(t, func() { CompressionMiddleware(WithCompressionLevel(42)) })
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{"gzip", "deflate"}
+
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP", "gzip"},
+		{"gzip;q=0", ""},
+		{"*", "gzip"},
+		{"*, gzip;q=0", "deflate"},
+		{"identity", ""},
+		{"br", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			assert.Equal(t, tt.expected, negotiateEncoding(tt.acceptEncoding, supported))
+		})
+	}
+}
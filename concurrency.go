@@ -0,0 +1,316 @@
+package httpbox
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	// PriorityCritical requests bypass the limiter entirely. It is meant for
+	// health checks, which must keep answering while the service sheds load
+	PriorityCritical
+)
+
+type PriorityFunc func(r *http.Request) Priority
+
+// PriorityByPath marks the given paths as critical, such as health checks
+func PriorityByPath(paths ...string) PriorityFunc {
+	critical := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		critical[path] = true
+	}
+
+	return func(r *http.Request) Priority {
+		if critical[r.URL.Path] {
+			return PriorityCritical
+		}
+
+		return PriorityNormal
+	}
+}
+
+type concurrencyConfig struct {
+	queueSize   int
+	queueWait   time.Duration
+	perRoute    bool
+	priority    PriorityFunc
+	retryAfter  time.Duration
+	adaptive    bool
+	minLimit    int
+	maxLimit    int
+	targetDelay time.Duration
+}
+
+type ConcurrencyOption func(*concurrencyConfig)
+
+// WithConcurrencyQueue lets up to size requests wait at most maxWait for a slot
+// instead of being rejected immediately
+func WithConcurrencyQueue(size int, maxWait time.Duration) ConcurrencyOption {
+	return func(cfg *concurrencyConfig) {
+		cfg.queueSize = size
+		cfg.queueWait = maxWait
+	}
+}
+
+// WithConcurrencyPerRoute applies the limit to each route pattern separately
+func WithConcurrencyPerRoute() ConcurrencyOption {
+	return func(cfg *concurrencyConfig) {
+		cfg.perRoute = true
+	}
+}
+
+func WithConcurrencyPriority(priority PriorityFunc) ConcurrencyOption {
+	return func(cfg *concurrencyConfig) {
+		cfg.priority = priority
+	}
+}
+
+func WithConcurrencyRetryAfter(retryAfter time.Duration) ConcurrencyOption {
+	return func(cfg *concurrencyConfig) {
+		cfg.retryAfter = retryAfter
+	}
+}
+
+// WithAdaptiveConcurrency adjusts the limit between minLimit and the limit given
+// to the middleware using AIMD: it grows by one for each limit's worth of
+// requests faster than targetLatency, and is halved when a request is slower.
+// Slow requests started before the last decrease do not halve it again, so a
+// burst of them only counts once
+func WithAdaptiveConcurrency(minLimit int, targetLatency time.Duration) ConcurrencyOption {
+	return func(cfg *concurrencyConfig) {
+		cfg.adaptive = true
+		cfg.minLimit = minLimit
+		cfg.targetDelay = targetLatency
+	}
+}
+
+// ConcurrencyLimitMiddleware bounds the number of requests served at the same
+// time, rejecting the excess with 503 errors. When queued, higher priority
+// requests are admitted first
+func ConcurrencyLimitMiddleware(limit int, opts ...ConcurrencyOption) Middleware {
+	cfg := &concurrencyConfig{
+		priority:   func(r *http.Request) Priority { return PriorityNormal },
+		retryAfter: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	cfg.maxLimit = limit
+	cfg.minLimit = max(1, min(cfg.minLimit, limit))
+
+	var mu sync.Mutex
+	limiters := make(map[string]*concurrencyLimiter)
+
+	limiterFor := func(r *http.Request) *concurrencyLimiter {
+		key := ""
+		if cfg.perRoute {
+			key = routePattern(r)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		l, ok := limiters[key]
+		if !ok {
+			l = newConcurrencyLimiter(cfg)
+			limiters[key] = l
+		}
+
+		return l
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			priority := cfg.priority(r)
+			if priority >= PriorityCritical {
+				return h(w, r)
+			}
+
+			l := limiterFor(r)
+
+			if err := l.acquire(r.Context(), priority); err != nil {
+				if r.Context().Err() != nil {
+					return r.Context().Err()
+				}
+
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(cfg.retryAfter)))
+				return NewError(http.StatusServiceUnavailable, "server is overloaded, retry later")
+			}
+
+			start := time.Now()
+			defer l.release(start)
+
+			return h(w, r)
+		}
+	}
+}
+
+type concurrencyWaiter struct {
+	priority Priority
+	// Receives true when a slot is handed over and false when evicted
+	admitted chan bool
+}
+
+type concurrencyLimiter struct {
+	cfg *concurrencyConfig
+
+	mu           sync.Mutex
+	limit        float64
+	inFlight     int
+	queue        []*concurrencyWaiter
+	lastDecrease time.Time
+}
+
+func newConcurrencyLimiter(cfg *concurrencyConfig) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		cfg:   cfg,
+		limit: float64(cfg.maxLimit),
+	}
+}
+
+var errConcurrencyShed = errors.New("concurrency limit reached")
+
+func (l *concurrencyLimiter) acquire(ctx context.Context, priority Priority) error {
+	l.mu.Lock()
+
+	if l.inFlight < int(l.limit) && len(l.queue) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return nil
+	}
+
+	if len(l.queue) >= l.cfg.queueSize && !l.evictLowerPriority(priority) {
+		l.mu.Unlock()
+		return errConcurrencyShed
+	}
+
+	waiter := &concurrencyWaiter{priority: priority, admitted: make(chan bool, 1)}
+	l.enqueue(waiter)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.queueWait)
+	defer timer.Stop()
+
+	select {
+	case admitted := <-waiter.admitted:
+		if !admitted {
+			return errConcurrencyShed
+		}
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.remove(waiter) {
+		return errConcurrencyShed
+	}
+
+	// The waiter left the queue while giving up, so the outcome was already sent
+	if !<-waiter.admitted {
+		return errConcurrencyShed
+	}
+
+	if ctx.Err() != nil {
+		l.releaseLocked()
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// enqueue keeps the queue sorted by priority, in arrival order within a priority
+func (l *concurrencyLimiter) enqueue(waiter *concurrencyWaiter) {
+	i := len(l.queue)
+	for i > 0 && l.queue[i-1].priority < waiter.priority {
+		i--
+	}
+
+	l.queue = append(l.queue, nil)
+	copy(l.queue[i+1:], l.queue[i:])
+	l.queue[i] = waiter
+}
+
+// evictLowerPriority makes room in a full queue by rejecting its last waiter if
+// it has a lower priority than the incoming request
+func (l *concurrencyLimiter) evictLowerPriority(priority Priority) bool {
+	if len(l.queue) == 0 {
+		return false
+	}
+
+	last := l.queue[len(l.queue)-1]
+	if last.priority >= priority {
+		return false
+	}
+
+	l.queue = l.queue[:len(l.queue)-1]
+	last.admitted <- false
+
+	return true
+}
+
+func (l *concurrencyLimiter) remove(waiter *concurrencyWaiter) bool {
+	for i, w := range l.queue {
+		if w == waiter {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *concurrencyLimiter) release(start time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.adaptive {
+		now := time.Now()
+
+		if now.Sub(start) > l.cfg.targetDelay {
+			// Requests admitted under the previous limit already had their
+			// slowness accounted for
+			if !start.Before(l.lastDecrease) {
+				l.limit = math.Max(float64(l.cfg.minLimit), l.limit/2)
+				l.lastDecrease = now
+			}
+		} else {
+			// Additive increase of one slot per limit's worth of fast requests
+			l.limit = math.Min(float64(l.cfg.maxLimit), l.limit+1/l.limit)
+		}
+	}
+
+	l.releaseLocked()
+}
+
+func (l *concurrencyLimiter) releaseLocked() {
+	l.inFlight--
+
+	for l.inFlight < int(l.limit) && len(l.queue) > 0 {
+		next := l.queue[0]
+		l.queue = l.queue[1:]
+		l.inFlight++
+		next.admitted <- true
+	}
+}
+
+func (l *concurrencyLimiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
@@ -0,0 +1,229 @@
+package httpbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler signals when a request starts and waits until released
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+}
+
+func serveAsync(h http.Handler, target string) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		done <- rec
+	}()
+
+	return done
+}
+
+func TestConcurrencyLimitMiddleware_Sheds(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	h := blockingHandler(started, release).WithMiddlewares(ConcurrencyLimitMiddleware(1,
+		WithConcurrencyPriority(PriorityByPath("/healthz")),
+		WithConcurrencyRetryAfter(2*time.Second),
+	))
+
+	first := serveAsync(h, "/work")
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/work", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+
+	health := serveAsync(h, "/healthz")
+	<-started
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-health).Code)
+	assert.Equal(t, http.StatusOK, (<-first).Code)
+}
+
+func TestConcurrencyLimitMiddleware_Queue(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+
+	h := blockingHandler(started, release).WithMiddlewares(ConcurrencyLimitMiddleware(1,
+		WithConcurrencyQueue(1, time.Second),
+	))
+
+	first := serveAsync(h, "/work")
+	<-started
+
+	second := serveAsync(h, "/work")
+	close(release)
+
+	assert.Equal(t, http.StatusOK, (<-first).Code)
+	assert.Equal(t, http.StatusOK, (<-second).Code)
+}
+
+func TestConcurrencyLimitMiddleware_QueueTimeout(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+
+	h := blockingHandler(started, release).WithMiddlewares(ConcurrencyLimitMiddleware(1,
+		WithConcurrencyQueue(1, 10*time.Millisecond),
+	))
+
+	serveAsync(h, "/work")
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/work", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestConcurrencyLimitMiddleware_PerRoute(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+
+	rt := NewRouter()
+	rt.Use(ConcurrencyLimitMiddleware(1, WithConcurrencyPerRoute()))
+	rt.Handle("GET /a", blockingHandler(started, release))
+	rt.Handle("GET /b", blockingHandler(started, release))
+
+	a := serveAsync(rt, "/a")
+	b := serveAsync(rt, "/b")
+	<-started
+	<-started
+
+	close(release)
+
+	assert.Equal(t, http.StatusOK, (<-a).Code)
+	assert.Equal(t, http.StatusOK, (<-b).Code)
+}
+
+func newTestConcurrencyLimiter(limit, queueSize int) *concurrencyLimiter {
+	return newConcurrencyLimiter(&concurrencyConfig{
+		maxLimit:  limit,
+		minLimit:  1,
+		queueSize: queueSize,
+		queueWait: time.Second,
+	})
+}
+
+func TestConcurrencyLimiter_PriorityOrder(t *testing.T) {
+	l := newTestConcurrencyLimiter(1, 3)
+	require.NoError(t, l.acquire(context.Background(), PriorityNormal))
+
+	order := make(chan Priority, 3)
+	for i, priority := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		go func() {
+			if l.acquire(context.Background(), priority) == nil {
+				order <- priority
+				l.release(time.Now())
+			}
+		}()
+
+		require.Eventually(t, func() bool {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return len(l.queue) == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	l.release(time.Now())
+
+	assert.Equal(t, PriorityHigh, <-order)
+	assert.Equal(t, PriorityNormal, <-order)
+	assert.Equal(t, PriorityLow, <-order)
+}
+
+func TestConcurrencyLimiter_EvictsLowerPriority(t *testing.T) {
+	l := newTestConcurrencyLimiter(1, 1)
+	require.NoError(t, l.acquire(context.Background(), PriorityNormal))
+
+	low := make(chan error, 1)
+	go func() { low <- l.acquire(context.Background(), PriorityLow) }()
+
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return len(l.queue) == 1
+	}, time.Second, time.Millisecond)
+
+	high := make(chan error, 1)
+	go func() { high <- l.acquire(context.Background(), PriorityHigh) }()
+
+	assert.ErrorIs(t, <-low, errConcurrencyShed)
+
+	l.release(time.Now())
+	assert.NoError(t, <-high)
+}
+
+func TestConcurrencyLimiter_Adaptive(t *testing.T) {
+	l := newConcurrencyLimiter(&concurrencyConfig{
+		maxLimit:    8,
+		minLimit:    2,
+		adaptive:    true,
+		targetDelay: 10 * time.Millisecond,
+	})
+
+	for range 3 {
+		require.NoError(t, l.acquire(context.Background(), PriorityNormal))
+		start := time.Now()
+		time.Sleep(15 * time.Millisecond)
+		l.release(start)
+	}
+
+	assert.Equal(t, 2, l.currentLimit(), "limit must not drop below the minimum")
+
+	for range 20 {
+		require.NoError(t, l.acquire(context.Background(), PriorityNormal))
+		l.release(time.Now())
+	}
+
+	assert.Greater(t, l.currentLimit(), 2)
+	assert.LessOrEqual(t, l.currentLimit(), 8)
+}
+
+func TestConcurrencyLimiter_AdaptiveBurst(t *testing.T) {
+	l := newConcurrencyLimiter(&concurrencyConfig{
+		maxLimit:    100,
+		minLimit:    1,
+		adaptive:    true,
+		targetDelay: 10 * time.Millisecond,
+	})
+
+	start := time.Now().Add(-time.Second)
+
+	for range 7 {
+		require.NoError(t, l.acquire(context.Background(), PriorityNormal))
+	}
+
+	for range 7 {
+		l.release(start)
+	}
+
+	assert.Equal(t, 50, l.currentLimit(), "a burst of slow requests must only halve the limit once")
+
+	// A request admitted after the decrease and still slow halves it again
+	require.NoError(t, l.acquire(context.Background(), PriorityNormal))
+	start = time.Now()
+	time.Sleep(20 * time.Millisecond)
+	l.release(start)
+
+	assert.Equal(t, 25, l.currentLimit())
+}
@@ -0,0 +1,386 @@
+package httpbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ComputeETag returns an entity tag derived from the hash of body
+func ComputeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	return hashETag(sum[:], weak)
+}
+
+func hashETag(sum []byte, weak bool) string {
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+
+	if weak {
+		return "W/" + etag
+	}
+
+	return etag
+}
+
+// formatETag quotes opaque values such as version numbers, leaving values
+// that already are entity tags as is
+func formatETag(value string) string {
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, `W/"`) {
+		return value
+	}
+
+	return `"` + value + `"`
+}
+
+// parseETags parses a list of entity tags as found in If-Match and
+// If-None-Match headers. Parsing stops at the first malformed entry
+func parseETags(header string) []string {
+	var etags []string
+
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return etags
+		}
+
+		if header[0] == '*' {
+			etags = append(etags, "*")
+			header = header[1:]
+			continue
+		}
+
+		start := 0
+		if strings.HasPrefix(header, "W/") {
+			start = 2
+		}
+
+		if len(header) <= start || header[start] != '"' {
+			return etags
+		}
+
+		// Entity tags cannot contain quotes, but may contain commas
+		end := strings.IndexByte(header[start+1:], '"')
+		if end < 0 {
+			return etags
+		}
+
+		end += start + 2
+		etags = append(etags, header[:end])
+		header = header[end:]
+	}
+}
+
+// etagWeakMatch compares entity tags ignoring their weakness, as done for
+// If-None-Match
+func etagWeakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// etagStrongMatch compares entity tags that must both be strong, as done for
+// If-Match
+func etagStrongMatch(a, b string) bool {
+	return a == b && !strings.HasPrefix(a, "W/")
+}
+
+// etagListMatches reports whether the list of entity tags in header matches
+// etag, "*" matching any current representation
+func etagListMatches(header, etag string, exists bool, match func(a, b string) bool) bool {
+	for _, candidate := range parseETags(header) {
+		if candidate == "*" && exists {
+			return true
+		}
+
+		if etag != "" && match(candidate, etag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNotModified evaluates If-None-Match and If-Modified-Since against the
+// ETag and Last-Modified headers of the response
+func isNotModified(r *http.Request, header http.Header) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-Modified-Since is ignored when If-None-Match is present
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, header.Get("ETag"), true, etagWeakMatch)
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.After(ims)
+}
+
+// writeNotModified sends a 304 response, keeping the validators and caching
+// headers already set
+func writeNotModified(w http.ResponseWriter) {
+	header := w.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+
+	w.WriteHeader(http.StatusNotModified)
+}
+
+type responseConfig struct {
+	request      *http.Request
+	etag         string
+	computeETag  bool
+	weakETag     bool
+	lastModified time.Time
+	disposition  string
+}
+
+type ResponseOption func(*responseConfig)
+
+// WithConditionalRequest evaluates the If-None-Match and If-Modified-Since
+// headers of r, replying 304 Not Modified instead of the body when the client
+// already has the current representation
+func WithConditionalRequest(r *http.Request) ResponseOption {
+	return func(cfg *responseConfig) {
+		cfg.request = r
+	}
+}
+
+// WithETag sets a strong ETag computed from the response body
+func WithETag() ResponseOption {
+	return func(cfg *responseConfig) {
+		cfg.computeETag = true
+		cfg.weakETag = false
+	}
+}
+
+// WithWeakETag sets a weak ETag computed from the response body
+func WithWeakETag() ResponseOption {
+	return func(cfg *responseConfig) {
+		cfg.computeETag = true
+		cfg.weakETag = true
+	}
+}
+
+// WithETagValue sets a precomputed ETag, such as a version number, quoting it
+// if needed
+func WithETagValue(etag string) ResponseOption {
+	return func(cfg *responseConfig) {
+		cfg.etag = formatETag(etag)
+	}
+}
+
+func WithLastModified(lastModified time.Time) ResponseOption {
+	return func(cfg *responseConfig) {
+		cfg.lastModified = lastModified
+	}
+}
+
+// writeBody writes a buffered body, applying the validators and conditional
+// request evaluation requested through opts
+func writeBody(w http.ResponseWriter, code int, contentType string, body []byte, opts []ResponseOption) error {
+	cfg := &responseConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	header := w.Header()
+
+	switch {
+	case cfg.etag != "":
+		header.Set("ETag", cfg.etag)
+	case cfg.computeETag:
+		header.Set("ETag", ComputeETag(body, cfg.weakETag))
+	}
+
+	if !cfg.lastModified.IsZero() {
+		header.Set("Last-Modified", cfg.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if cfg.disposition != "" {
+		header.Set("Content-Disposition", cfg.disposition)
+	}
+
+	if cfg.request != nil && code == http.StatusOK && isNotModified(cfg.request, header) {
+		writeNotModified(w)
+		return nil
+	}
+
+	header.Set("Content-Type", contentType)
+	w.WriteHeader(code)
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type etagConfig struct {
+	weak    bool
+	maxSize int
+}
+
+type ETagOption func(*etagConfig)
+
+func WithWeakETags() ETagOption {
+	return func(cfg *etagConfig) {
+		cfg.weak = true
+	}
+}
+
+// WithETagMaxSize sets the size above which responses are streamed without an
+// ETag instead of being buffered
+func WithETagMaxSize(size int) ETagOption {
+	return func(cfg *etagConfig) {
+		cfg.maxSize = size
+	}
+}
+
+// ETagMiddleware buffers successful GET and HEAD responses to add an ETag
+// computed from their body, unless the handler set one, and replies 304 Not
+// Modified to conditional requests matching it. Flushed responses and
+// responses larger than the maximum size are streamed as is
+func ETagMiddleware(opts ...ETagOption) Middleware {
+	cfg := &etagConfig{maxSize: 1 << 20}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				return h(w, r)
+			}
+
+			ew := &etagWriter{ResponseWriter: w, maxSize: cfg.maxSize}
+
+			if err := h(ew, r); err != nil {
+				ew.stream()
+				return err
+			}
+
+			if !ew.buffering() {
+				return nil
+			}
+
+			if w.Header().Get("ETag") == "" {
+				w.Header().Set("ETag", ComputeETag(ew.buf.Bytes(), cfg.weak))
+			}
+
+			if isNotModified(r, w.Header()) {
+				writeNotModified(w)
+				return nil
+			}
+
+			return ew.stream()
+		}
+	}
+}
+
+type etagWriter struct {
+	http.ResponseWriter
+	maxSize int
+
+	statusCode  int
+	wroteHeader bool
+	streaming   bool
+	buf         bytes.Buffer
+}
+
+// buffering reports whether the whole response of the handler is buffered
+func (ew *etagWriter) buffering() bool {
+	return ew.wroteHeader && !ew.streaming
+}
+
+func (ew *etagWriter) WriteHeader(statusCode int) {
+	if ew.wroteHeader {
+		return
+	}
+
+	if statusCode < 200 {
+		ew.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	ew.statusCode = statusCode
+	ew.wroteHeader = true
+
+	if statusCode != http.StatusOK {
+		ew.stream()
+	}
+}
+
+func (ew *etagWriter) Write(b []byte) (int, error) {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+
+	if !ew.streaming && ew.buf.Len()+len(b) > ew.maxSize {
+		if err := ew.stream(); err != nil {
+			return 0, err
+		}
+	}
+
+	if ew.streaming {
+		return ew.ResponseWriter.Write(b)
+	}
+
+	return ew.buf.Write(b)
+}
+
+// stream sends the status and what was buffered so far, writing the rest of
+// the response directly
+func (ew *etagWriter) stream() error {
+	if !ew.wroteHeader || ew.streaming {
+		return nil
+	}
+
+	ew.streaming = true
+	ew.ResponseWriter.WriteHeader(ew.statusCode)
+
+	if ew.buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := ew.ResponseWriter.Write(ew.buf.Bytes())
+	ew.buf.Reset()
+
+	return err
+}
+
+func (ew *etagWriter) FlushError() error {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+
+	if err := ew.stream(); err != nil {
+		return err
+	}
+
+	return http.NewResponseController(ew.ResponseWriter).Flush()
+}
+
+func (ew *etagWriter) Flush() {
+	ew.FlushError()
+}
+
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
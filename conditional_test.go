@@ -0,0 +1,236 @@
+package httpbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeETag(t *testing.T) {
+	strong := ComputeETag([]byte("hello"), false)
+	weak := ComputeETag([]byte("hello"), true)
+
+	assert.Regexp(t, `^"[A-Za-z0-9_-]{24}"$`, strong)
+	assert.Equal(t, "W/"+strong, weak)
+	assert.NotEqual(t, strong, ComputeETag([]byte("world"), false))
+}
+
+func TestParseETags(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected []string
+	}{
+		{"", nil},
+		{`"a"`, []string{`"a"`}},
+		{`"a", W/"b"`, []string{`"a"`, `W/"b"`}},
+		{`"a,b" , "c"`, []string{`"a,b"`, `"c"`}},
+		{"*", []string{"*"}},
+		{`"a", b`, []string{`"a"`}},
+		{`"unterminated`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseETags(tt.header))
+		})
+	}
+}
+
+func TestIsNotModified(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		expected bool
+	}{
+		{"no conditions", http.MethodGet, nil, false},
+		{"matching ETag", http.MethodGet, map[string]string{"If-None-Match": `"v1"`}, true},
+		{"weak match", http.MethodGet, map[string]string{"If-None-Match": `W/"v1"`}, true},
+		{"ETag in list", http.MethodGet, map[string]string{"If-None-Match": `"v0", "v1"`}, true},
+		{"star", http.MethodGet, map[string]string{"If-None-Match": "*"}, true},
+		{"other ETag", http.MethodGet, map[string]string{"If-None-Match": `"v2"`}, false},
+		{"not modified since", http.MethodGet, map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, true},
+		{"modified since", http.MethodGet, map[string]string{"If-Modified-Since": lastModified.Add(-time.Second).Format(http.TimeFormat)}, false},
+		{"invalid date", http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, false},
+		{
+			"If-None-Match takes precedence",
+			http.MethodGet,
+			map[string]string{"If-None-Match": `"v2"`, "If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			false,
+		},
+		{"HEAD", http.MethodHead, map[string]string{"If-None-Match": `"v1"`}, true},
+		{"POST", http.MethodPost, map[string]string{"If-None-Match": `"v1"`}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/test", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			header := http.Header{}
+			header.Set("ETag", `"v1"`)
+			header.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+			assert.Equal(t, tt.expected, isNotModified(req, header))
+		})
+	}
+}
+
+func TestWriteJSON_ConditionalRequest(t *testing.T) {
+	data := map[string]string{"status": "ok"}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+
+	require.NoError(t, WriteJSON(rec, http.StatusOK, data, WithConditionalRequest(req), WithETag()))
+
+	etag := rec.Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, etag)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	req.Header.Set("If-None-Match", etag)
+
+	require.NoError(t, WriteJSON(rec, http.StatusOK, data, WithConditionalRequest(req), WithETag()))
+
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+	assert.Empty(t, rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestWriteBytes_Validators(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+
+	rec := httptest.NewRecorder()
+	err := WriteBytes(rec, http.StatusOK, "text/plain", []byte("hello"),
+		WithETagValue("42"),
+		WithLastModified(lastModified),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, `"42"`, rec.Header().Get("ETag"))
+	assert.Equal(t, "Tue, 02 Jan 2024 02:04:05 GMT", rec.Header().Get("Last-Modified"))
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, WriteBytes(rec, http.StatusOK, "text/plain", []byte("hello"), WithWeakETag()))
+	assert.True(t, strings.HasPrefix(rec.Header().Get("ETag"), `W/"`))
+}
+
+func TestWriteXML_ConditionalRequestIgnoredForErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("If-None-Match", "*")
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, WriteXML(rec, http.StatusNotFound, testResponse{Message: "missing"}, WithConditionalRequest(req), WithETag()))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "missing")
+}
+
+func serveETag(h Handler, method string, headers map[string]string, opts ...ETagOption) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/test", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	h.WithMiddlewares(ETagMiddleware(opts...)).ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestETagMiddleware(t *testing.T) {
+	expectedETag := ComputeETag([]byte("hello"), false)
+
+	rec := serveETag(textHandler("hello"), http.MethodGet, nil)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, expectedETag, rec.Header().Get("ETag"))
+	assert.Equal(t, "hello", rec.Body.String())
+
+	rec = serveETag(textHandler("hello"), http.MethodGet, map[string]string{"If-None-Match": expectedETag})
+
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = serveETag(textHandler("hello"), http.MethodGet, nil, WithWeakETags())
+
+	assert.Equal(t, "W/"+expectedETag, rec.Header().Get("ETag"))
+}
+
+func TestETagMiddleware_HandlerETag(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte("hello"), WithETagValue("v1"))
+	})
+
+	rec := serveETag(h, http.MethodGet, map[string]string{"If-None-Match": `"v1"`})
+
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, `"v1"`, rec.Header().Get("ETag"))
+}
+
+func TestETagMiddleware_Skipped(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		handler Handler
+		opts    []ETagOption
+	}{
+		{
+			name:    "POST",
+			method:  http.MethodPost,
+			handler: textHandler("hello"),
+		},
+		{
+			name:   "non 200 status",
+			method: http.MethodGet,
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return WriteBytes(w, http.StatusCreated, "text/plain", []byte("hello"))
+			},
+		},
+		{
+			name:    "too large",
+			method:  http.MethodGet,
+			handler: textHandler(strings.Repeat("a", 100)),
+			opts:    []ETagOption{WithETagMaxSize(10)},
+		},
+		{
+			name:   "flushed",
+			method: http.MethodGet,
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.Write([]byte("hel"))
+				http.NewResponseController(w).Flush()
+				w.Write([]byte("lo"))
+				return nil
+			},
+		},
+		{
+			name:   "error",
+			method: http.MethodGet,
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return NewError(http.StatusNotFound, "not found")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveETag(tt.handler, tt.method, map[string]string{"If-None-Match": "*"}, tt.opts...)
+
+			assert.NotEqual(t, http.StatusNotModified, rec.Code)
+			assert.Empty(t, rec.Header().Get("ETag"))
+			assert.NotEmpty(t, rec.Body.String())
+		})
+	}
+}
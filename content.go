@@ -0,0 +1,288 @@
+package httpbox
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Requests asking for more ranges are served the whole content, as each range
+// adds a part header to the response
+const maxRanges = 64
+
+// WithAttachment sets a Content-Disposition header prompting browsers to
+// download the response as filename
+func WithAttachment(filename string) ResponseOption {
+	return func(cfg *responseConfig) {
+		cfg.disposition = mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	}
+}
+
+// WriteContent writes content, answering range requests with 206 Partial
+// Content responses so that downloads can be resumed and media players can
+// seek. The Content-Type is derived from the extension of name, or sniffed.
+//
+// Conditional requests are evaluated against the validators set through
+// WithETag, WithWeakETag, WithETagValue and WithLastModified, the body-based
+// ETags being computed by reading content once. Failed preconditions, up to
+// date clients and unsatisfiable ranges are reported as *Error values
+func WriteContent(w http.ResponseWriter, r *http.Request, name string, content io.ReadSeeker, opts ...ResponseOption) error {
+	cfg := &responseConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("seeking content end: %w", err)
+	}
+
+	etag := cfg.etag
+	if etag == "" && cfg.computeETag {
+		if etag, err = computeReaderETag(content, cfg.weakETag); err != nil {
+			return err
+		}
+	}
+
+	if err := CheckPreconditions(w, r, ResourceVersion{ETag: etag, LastModified: cfg.lastModified}); err != nil {
+		return err
+	}
+
+	ranges, err := requestedRanges(r, size, etag, cfg.lastModified)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return err
+	}
+
+	header := w.Header()
+	header.Set("Accept-Ranges", "bytes")
+
+	if cfg.disposition != "" {
+		header.Set("Content-Disposition", cfg.disposition)
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		if contentType, err = detectContentType(name, content); err != nil {
+			return err
+		}
+	}
+
+	switch len(ranges) {
+	case 0:
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(http.StatusOK)
+
+		return copyRange(w, r, content, httpRange{0, size})
+
+	case 1:
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Range", ranges[0].contentRange(size))
+		header.Set("Content-Length", strconv.FormatInt(ranges[0].length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+
+		return copyRange(w, r, content, ranges[0])
+
+	default:
+		mw := multipart.NewWriter(w)
+
+		header.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		w.WriteHeader(http.StatusPartialContent)
+
+		if r.Method == http.MethodHead {
+			return nil
+		}
+
+		for _, ra := range ranges {
+			part, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":  {contentType},
+				"Content-Range": {ra.contentRange(size)},
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := copyRange(part, r, content, ra); err != nil {
+				return err
+			}
+		}
+
+		return mw.Close()
+	}
+}
+
+type httpRange struct {
+	start  int64
+	length int64
+}
+
+func (ra httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", ra.start, ra.start+ra.length-1, size)
+}
+
+func copyRange(w io.Writer, r *http.Request, content io.ReadSeeker, ra httpRange) error {
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	if _, err := content.Seek(ra.start, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := io.CopyN(w, content, ra.length)
+	return err
+}
+
+func computeReaderETag(content io.ReadSeeker, weak bool) (string, error) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+
+	return hashETag(hash.Sum(nil), weak), nil
+}
+
+func detectContentType(name string, content io.ReadSeeker) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType, nil
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	var buf [512]byte
+	n, err := io.ReadFull(content, buf[:])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// requestedRanges returns the ranges to send, or none to send the whole content.
+// Malformed Range headers are ignored, as allowed by RFC 9110
+func requestedRanges(r *http.Request, size int64, etag string, lastModified time.Time) ([]httpRange, error) {
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return nil, nil
+	}
+
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && !ifRangeMatches(ifRange, etag, lastModified) {
+		return nil, nil
+	}
+
+	ranges, ok := parseRange(rangeHeader, size)
+	if !ok {
+		return nil, nil
+	}
+
+	if len(ranges) == 0 {
+		return nil, NewError(http.StatusRequestedRangeNotSatisfiable, "range not satisfiable")
+	}
+
+	var total int64
+	for _, ra := range ranges {
+		total += ra.length
+	}
+
+	// Overlapping ranges asking for more than the whole content are not worth
+	// the multipart overhead
+	if len(ranges) > maxRanges || total > size {
+		return nil, nil
+	}
+
+	return ranges, nil
+}
+
+// ifRangeMatches evaluates an If-Range header, which holds either a strong
+// entity tag or a date that must exactly match the current validators
+func ifRangeMatches(ifRange, etag string, lastModified time.Time) bool {
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, `W/"`) {
+		return etagStrongMatch(ifRange, etag)
+	}
+
+	date, err := http.ParseTime(ifRange)
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+
+	return lastModified.Truncate(time.Second).Equal(date)
+}
+
+// parseRange parses a bytes Range header, dropping ranges that start beyond
+// size. It reports false when the header is malformed
+func parseRange(header string, size int64) ([]httpRange, bool) {
+	specs, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, false
+	}
+
+	var ranges []httpRange
+	parsed := 0
+
+	for spec := range strings.SplitSeq(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		parsed++
+
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, false
+		}
+
+		if first == "" {
+			// Suffix range, such as -500 for the last 500 bytes
+			length, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || length < 0 {
+				return nil, false
+			}
+
+			if length == 0 || size == 0 {
+				continue
+			}
+
+			length = min(length, size)
+			ranges = append(ranges, httpRange{start: size - length, length: length})
+			continue
+		}
+
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, false
+		}
+
+		end := size - 1
+		if last != "" {
+			if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+				return nil, false
+			}
+		}
+
+		if start >= size {
+			continue
+		}
+
+		end = min(end, size-1)
+		ranges = append(ranges, httpRange{start: start, length: end - start + 1})
+	}
+
+	return ranges, parsed > 0
+}
@@ -0,0 +1,192 @@
+package httpbox
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testContent = "0123456789abcdefghij"
+
+func serveContent(method string, headers map[string]string, name string, opts ...ResponseOption) *httptest.ResponseRecorder {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WriteContent(w, r, name, strings.NewReader(testContent), opts...)
+	})
+
+	req := httptest.NewRequest(method, "/file", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestWriteContent_Full(t *testing.T) {
+	rec := serveContent(http.MethodGet, nil, "report.txt", WithAttachment("rapport d'été.txt"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, testContent, rec.Body.String())
+	assert.Equal(t, "20", rec.Header().Get("Content-Length"))
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	disposition, params, err := mime.ParseMediaType(rec.Header().Get("Content-Disposition"))
+	require.NoError(t, err)
+	assert.Equal(t, "attachment", disposition)
+	assert.Equal(t, "rapport d'été.txt", params["filename"])
+}
+
+func TestWriteContent_SniffsContentType(t *testing.T) {
+	rec := serveContent(http.MethodGet, nil, "")
+
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestWriteContent_Head(t *testing.T) {
+	rec := serveContent(http.MethodHead, map[string]string{"Range": "bytes=0-4"}, "file.bin")
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "5", rec.Header().Get("Content-Length"))
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestWriteContent_SingleRange(t *testing.T) {
+	tests := []struct {
+		rangeHeader   string
+		expectedRange string
+		expectedBody  string
+	}{
+		{"bytes=0-4", "bytes 0-4/20", "01234"},
+		{"bytes=15-", "bytes 15-19/20", "fghij"},
+		{"bytes=-3", "bytes 17-19/20", "hij"},
+		{"bytes=18-100", "bytes 18-19/20", "ij"},
+		{"bytes=-100", "bytes 0-19/20", testContent},
+		{"bytes=30-40, 2-3", "bytes 2-3/20", "23"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rangeHeader, func(t *testing.T) {
+			rec := serveContent(http.MethodGet, map[string]string{"Range": tt.rangeHeader}, "file.bin")
+
+			assert.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, tt.expectedRange, rec.Header().Get("Content-Range"))
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestWriteContent_MultipleRanges(t *testing.T) {
+	rec := serveContent(http.MethodGet, map[string]string{"Range": "bytes=0-1, 10-12"}, "file.txt")
+
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+
+	mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/byteranges", mediaType)
+
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+
+	expected := []struct{ contentRange, body string }{
+		{"bytes 0-1/20", "01"},
+		{"bytes 10-12/20", "abc"},
+	}
+
+	for _, part := range expected {
+		p, err := mr.NextPart()
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(p)
+		require.NoError(t, err)
+
+		assert.Equal(t, part.contentRange, p.Header.Get("Content-Range"))
+		assert.Equal(t, "text/plain; charset=utf-8", p.Header.Get("Content-Type"))
+		assert.Equal(t, part.body, string(body))
+	}
+
+	_, err = mr.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestWriteContent_IgnoredRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		rangeHeader string
+	}{
+		{"unknown unit", "items=0-1"},
+		{"malformed", "bytes=a-b"},
+		{"reversed", "bytes=5-1"},
+		{"empty", "bytes="},
+		{"overlapping ranges larger than content", "bytes=0-15, 5-19"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveContent(http.MethodGet, map[string]string{"Range": tt.rangeHeader}, "file.bin")
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, testContent, rec.Body.String())
+		})
+	}
+}
+
+func TestWriteContent_Unsatisfiable(t *testing.T) {
+	rec := serveContent(http.MethodGet, map[string]string{"Range": "bytes=20-"}, "file.bin")
+
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+	assert.Equal(t, "bytes */20", rec.Header().Get("Content-Range"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":416,"message":"range not satisfiable"}`, rec.Body.String())
+}
+
+func TestWriteContent_IfRange(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	opts := []ResponseOption{WithETagValue("v1"), WithLastModified(lastModified)}
+
+	tests := []struct {
+		name         string
+		ifRange      string
+		expectedCode int
+	}{
+		{"matching ETag", `"v1"`, http.StatusPartialContent},
+		{"other ETag", `"v0"`, http.StatusOK},
+		{"weak ETag", `W/"v1"`, http.StatusOK},
+		{"matching date", lastModified.Format(http.TimeFormat), http.StatusPartialContent},
+		{"other date", lastModified.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveContent(http.MethodGet, map[string]string{"Range": "bytes=0-4", "If-Range": tt.ifRange}, "file.bin", opts...)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestWriteContent_Conditional(t *testing.T) {
+	rec := serveContent(http.MethodGet, nil, "file.bin", WithETag())
+	etag := rec.Header().Get("ETag")
+
+	require.NotEmpty(t, etag)
+	assert.Equal(t, testContent, rec.Body.String())
+
+	rec = serveContent(http.MethodGet, map[string]string{"If-None-Match": etag}, "file.bin", WithETag())
+
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = serveContent(http.MethodGet, map[string]string{"If-Match": `"other"`}, "file.bin", WithETag())
+
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+}
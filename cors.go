@@ -0,0 +1,240 @@
+package httpbox
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type corsConfig struct {
+	allowAllOrigins  bool
+	origins          []string
+	wildcardOrigins  [][2]string
+	originFunc       func(origin string) bool
+	methods          []string
+	allowAllHeaders  bool
+	headers          []string
+	exposedHeaders   []string
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+type CORSOption func(*corsConfig)
+
+// WithCORSOrigins sets the allowed origins. Besides exact origins, "*" allows
+// any origin and a single "*" inside an origin matches subdomains, as in
+// "https://*.example.com"
+func WithCORSOrigins(origins ...string) CORSOption {
+	return func(cfg *corsConfig) {
+		for _, origin := range origins {
+			origin = strings.ToLower(origin)
+
+			if origin == "*" {
+				cfg.allowAllOrigins = true
+			} else if prefix, suffix, ok := strings.Cut(origin, "*"); ok {
+				cfg.wildcardOrigins = append(cfg.wildcardOrigins, [2]string{prefix, suffix})
+			} else {
+				cfg.origins = append(cfg.origins, origin)
+			}
+		}
+	}
+}
+
+func WithCORSOriginFunc(allow func(origin string) bool) CORSOption {
+	return func(cfg *corsConfig) {
+		cfg.originFunc = allow
+	}
+}
+
+func WithCORSMethods(methods ...string) CORSOption {
+	return func(cfg *corsConfig) {
+		cfg.methods = make([]string, len(methods))
+		for i, method := range methods {
+			cfg.methods[i] = strings.ToUpper(method)
+		}
+	}
+}
+
+// WithCORSHeaders sets the request headers clients may send. "*" allows any
+func WithCORSHeaders(headers ...string) CORSOption {
+	return func(cfg *corsConfig) {
+		for _, header := range headers {
+			if header == "*" {
+				cfg.allowAllHeaders = true
+			} else {
+				cfg.headers = append(cfg.headers, http.CanonicalHeaderKey(header))
+			}
+		}
+	}
+}
+
+func WithCORSExposedHeaders(headers ...string) CORSOption {
+	return func(cfg *corsConfig) {
+		cfg.exposedHeaders = append(cfg.exposedHeaders, headers...)
+	}
+}
+
+func WithCORSCredentials() CORSOption {
+	return func(cfg *corsConfig) {
+		cfg.allowCredentials = true
+	}
+}
+
+func WithCORSMaxAge(maxAge time.Duration) CORSOption {
+	return func(cfg *corsConfig) {
+		cfg.maxAge = maxAge
+	}
+}
+
+func CORSMiddleware(opts ...CORSOption) Middleware {
+	cfg := &corsConfig{
+		methods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			origin := r.Header.Get("Origin")
+
+			if origin != "" && r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				return cfg.handlePreflight(w, r, origin)
+			}
+
+			// The response depends on Origin unless every origin receives "*"
+			if !cfg.allowAllOrigins || cfg.allowCredentials {
+				w.Header().Add("Vary", "Origin")
+			}
+
+			if origin != "" && cfg.isOriginAllowed(origin) {
+				cfg.setAllowOrigin(w, origin)
+
+				if len(cfg.exposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.exposedHeaders, ", "))
+				}
+			}
+
+			return h(w, r)
+		}
+	}
+}
+
+func (cfg *corsConfig) handlePreflight(w http.ResponseWriter, r *http.Request, origin string) error {
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	if !cfg.isOriginAllowed(origin) {
+		return newCORSError("origin not allowed", origin)
+	}
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !cfg.isMethodAllowed(method) {
+		return newCORSError("method not allowed", method)
+	}
+
+	requested := parseHeaderList(r.Header.Values("Access-Control-Request-Headers"))
+	for _, name := range requested {
+		if !cfg.isHeaderAllowed(name) {
+			return newCORSError("header not allowed", name)
+		}
+	}
+
+	cfg.setAllowOrigin(w, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(cfg.methods, ", "))
+
+	if len(requested) > 0 {
+		// Echoing the requested headers works for both the explicit list and
+		// "*", which browsers ignore when credentials are allowed
+		header.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+
+	if cfg.maxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.maxAge.Seconds())))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func newCORSError(reason, value string) *Error {
+	return NewError(http.StatusForbidden, "CORS preflight request rejected", WithDetails(map[string]string{
+		"reason": reason,
+		"value":  value,
+	}))
+}
+
+func (cfg *corsConfig) setAllowOrigin(w http.ResponseWriter, origin string) {
+	// Browsers reject "*" on credentialed requests, so the origin is echoed
+	if cfg.allowAllOrigins && !cfg.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if cfg.allowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (cfg *corsConfig) isOriginAllowed(origin string) bool {
+	if cfg.allowAllOrigins {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+
+	if slices.Contains(cfg.origins, lower) {
+		return true
+	}
+
+	for _, wildcard := range cfg.wildcardOrigins {
+		prefix, suffix := wildcard[0], wildcard[1]
+
+		if len(lower) <= len(prefix)+len(suffix) || !strings.HasPrefix(lower, prefix) || !strings.HasSuffix(lower, suffix) {
+			continue
+		}
+
+		// The wildcard may only stand for subdomain labels
+		if !strings.ContainsAny(lower[len(prefix):len(lower)-len(suffix)], ":/@") {
+			return true
+		}
+	}
+
+	return cfg.originFunc != nil && cfg.originFunc(origin)
+}
+
+// isMethodAllowed accepts the CORS-safelisted methods, which browsers allow
+// whatever the Access-Control-Allow-Methods header says
+func (cfg *corsConfig) isMethodAllowed(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		return true
+	}
+
+	return slices.Contains(cfg.methods, method)
+}
+
+func (cfg *corsConfig) isHeaderAllowed(name string) bool {
+	return cfg.allowAllHeaders || slices.Contains(cfg.headers, http.CanonicalHeaderKey(name))
+}
+
+func parseHeaderList(values []string) []string {
+	var list []string
+
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, strings.ToLower(item))
+			}
+		}
+	}
+
+	return list
+}
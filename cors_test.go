@@ -0,0 +1,168 @@
+package httpbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCORSTestHandler(opts ...CORSOption) Handler {
+	return Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte("ok"))
+	}).WithMiddlewares(CORSMiddleware(opts...))
+}
+
+func newPreflightRequest(origin, method, headers string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, "/test", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return req
+}
+
+func TestCORSMiddleware_Origins(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []CORSOption
+		origin   string
+		expected string
+	}{
+		{"exact match", []CORSOption{WithCORSOrigins("https://app.example.com")}, "https://app.example.com", "https://app.example.com"},
+		{"exact match is case insensitive", []CORSOption{WithCORSOrigins("https://App.example.com")}, "https://app.EXAMPLE.com", "https://app.EXAMPLE.com"},
+		{"exact mismatch", []CORSOption{WithCORSOrigins("https://app.example.com")}, "https://evil.com", ""},
+		{"wildcard subdomain", []CORSOption{WithCORSOrigins("https://*.example.com")}, "https://a.b.example.com", "https://a.b.example.com"},
+		{"wildcard requires subdomain", []CORSOption{WithCORSOrigins("https://*.example.com")}, "https://.example.com", ""},
+		{"wildcard rejects other scheme", []CORSOption{WithCORSOrigins("https://*.example.com")}, "http://a.example.com", ""},
+		{"wildcard rejects suffix trick", []CORSOption{WithCORSOrigins("https://*.example.com")}, "https://evil.com:1@x.example.com", ""},
+		{"any origin", []CORSOption{WithCORSOrigins("*")}, "https://anything.dev", "*"},
+		{"any origin with credentials", []CORSOption{WithCORSOrigins("*"), WithCORSCredentials()}, "https://anything.dev", "https://anything.dev"},
+		{"predicate", []CORSOption{WithCORSOriginFunc(func(o string) bool { return strings.HasSuffix(o, ".local") })}, "http://dev.local", "http://dev.local"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+
+			newCORSTestHandler(tt.opts...).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.expected, rec.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func TestCORSMiddleware_ActualRequestHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+
+	newCORSTestHandler(
+		WithCORSOrigins("https://app.example.com"),
+		WithCORSExposedHeaders("X-Request-ID", "ETag"),
+		WithCORSCredentials(),
+	).ServeHTTP(rec, req)
+
+	assert.Equal(t, "X-Request-ID, ETag", rec.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, []string{"Origin"}, rec.Header().Values("Vary"))
+	assert.Equal(t, "ok", rec.Body.String())
+}
+
+func TestCORSMiddleware_NoVaryForAnyOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+
+	newCORSTestHandler(WithCORSOrigins("*")).ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Header().Values("Vary"))
+}
+
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := newPreflightRequest("https://app.example.com", http.MethodPut, "content-type, X-Custom")
+
+	newCORSTestHandler(
+		WithCORSOrigins("https://app.example.com"),
+		WithCORSMethods("get", "put"),
+		WithCORSHeaders("Content-Type", "x-custom"),
+		WithCORSMaxAge(10*time.Minute),
+	).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, PUT", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type, x-custom", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, rec.Header().Values("Vary"))
+}
+
+func TestCORSMiddleware_PreflightSafelistedMethod(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost} {
+		t.Run(method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := newPreflightRequest("https://app.example.com", method, "")
+
+			newCORSTestHandler(
+				WithCORSOrigins("https://app.example.com"),
+				WithCORSMethods(http.MethodPut),
+			).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func TestCORSMiddleware_PreflightRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    *http.Request
+		reason string
+	}{
+		{"origin", newPreflightRequest("https://evil.com", http.MethodGet, ""), "origin not allowed"},
+		{"method", newPreflightRequest("https://app.example.com", http.MethodDelete, ""), "method not allowed"},
+		{"header", newPreflightRequest("https://app.example.com", http.MethodGet, "X-Secret"), "header not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			newCORSTestHandler(WithCORSOrigins("https://app.example.com")).ServeHTTP(rec, tt.req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.reason)
+			assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func TestCORSMiddleware_AllHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := newPreflightRequest("https://app.example.com", http.MethodGet, "X-Anything")
+
+	newCORSTestHandler(WithCORSOrigins("*"), WithCORSHeaders("*")).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "x-anything", rec.Header().Get("Access-Control-Allow-Headers"))
+}
+
+func TestCORSMiddleware_OptionsWithoutPreflight(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/test", nil)
+
+	newCORSTestHandler(WithCORSOrigins("*")).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+}
@@ -0,0 +1,303 @@
+package httpbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"mime"
+	"net/http"
+	"reflect"
+)
+
+type CSVErrorDetails struct {
+	Row     int    `json:"row"`
+	Column  int    `json:"column,omitempty"`
+	Header  string `json:"header,omitempty"`
+	Error   string `json:"error"`
+	Details any    `json:"details,omitempty"`
+}
+
+type csvConfig struct {
+	comma    rune
+	header   []string
+	filename string
+}
+
+type CSVOption func(*csvConfig)
+
+// WithTSV uses tabs to separate values, with the text/tab-separated-values
+// content type
+func WithTSV() CSVOption {
+	return func(cfg *csvConfig) {
+		cfg.comma = '\t'
+	}
+}
+
+// WithCSVHeader sets the column names. When writing []string rows, it is the
+// header row. When reading, the body is expected to have no header row
+func WithCSVHeader(columns ...string) CSVOption {
+	return func(cfg *csvConfig) {
+		cfg.header = columns
+	}
+}
+
+// WithCSVFilename prompts browsers to download the response as filename
+func WithCSVFilename(filename string) CSVOption {
+	return func(cfg *csvConfig) {
+		cfg.filename = filename
+	}
+}
+
+func newCSVConfig(opts []CSVOption) *csvConfig {
+	cfg := &csvConfig{comma: ','}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+var stringsType = reflect.TypeFor[[]string]()
+
+// WriteCSV streams rows as CSV. Rows are either []string or structs, whose
+// header is derived from their csv tags or field names. Time fields are
+// formatted with the layout of their format tag
+func WriteCSV[T any](w http.ResponseWriter, r *http.Request, code int, rows iter.Seq[T], opts ...CSVOption) error {
+	cfg := newCSVConfig(opts)
+
+	rowType := reflect.TypeFor[T]()
+	if rowType != stringsType && rowType.Kind() != reflect.Struct {
+		return fmt.Errorf("httpbox: CSV rows must be structs or []string, got %s", rowType)
+	}
+
+	var fields []boundField
+	header := cfg.header
+
+	if rowType.Kind() == reflect.Struct {
+		fields = boundFields(rowType, "csv")
+
+		if header == nil {
+			for _, field := range fields {
+				header = append(header, field.name)
+			}
+		}
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if cfg.comma == '\t' {
+		contentType = "text/tab-separated-values; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	if cfg.filename != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": cfg.filename}))
+	}
+
+	w.WriteHeader(code)
+
+	cw := csv.NewWriter(w)
+	cw.Comma = cfg.comma
+
+	if header != nil {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(fields))
+
+	for row := range rows {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+
+		if fields == nil {
+			if err := cw.Write(any(row).([]string)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		v := reflect.ValueOf(row)
+		for i, field := range fields {
+			record[i] = formatValue(v.FieldByIndex(field.index), field.format)
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// ReadCSV decodes a CSV body one row at a time. Rows are either []string or
+// structs, whose fields are matched with columns through their csv tags or
+// names, and converted following the same rules as Param. Unknown columns and
+// empty cells are ignored.
+//
+// Invalid rows yield the zero value along with an error holding the row and
+// column numbers, while malformed CSV ends the iteration
+func ReadCSV[T any](r io.Reader, opts ...CSVOption) iter.Seq2[T, error] {
+	cfg := newCSVConfig(opts)
+
+	return func(yield func(T, error) bool) {
+		// Errors come with the zero value, never a partially bound row
+		var zero T
+
+		rowType := reflect.TypeFor[T]()
+		if rowType != stringsType && rowType.Kind() != reflect.Struct {
+			yield(zero, fmt.Errorf("httpbox: CSV rows must be structs or []string, got %s", rowType))
+			return
+		}
+
+		cr := csv.NewReader(skipByteOrderMark(r))
+		cr.Comma = cfg.comma
+		cr.ReuseRecord = true
+
+		header := cfg.header
+		if header != nil {
+			cr.FieldsPerRecord = len(header)
+		} else {
+			record, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(zero, csvReadError(err))
+				return
+			}
+			header = append([]string(nil), record...)
+		}
+
+		// Columns bound to each field, by position in the records
+		var columns []*boundField
+
+		if rowType.Kind() == reflect.Struct {
+			fields := make(map[string]boundField)
+			for _, field := range boundFields(rowType, "csv") {
+				fields[field.name] = field
+			}
+
+			columns = make([]*boundField, len(header))
+			for i, name := range header {
+				if field, ok := fields[name]; ok {
+					columns[i] = &field
+				}
+			}
+		}
+
+		for {
+			record, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			if err != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+					if !yield(zero, csvRowError(parseErr.StartLine, 0, "", err)) {
+						return
+					}
+					continue
+				}
+
+				yield(zero, csvReadError(err))
+				return
+			}
+
+			row, _ := cr.FieldPos(0)
+
+			v, err := bindCSVRecord[T](record, header, columns, row)
+			if err != nil {
+				if !yield(zero, err) {
+					return
+				}
+				continue
+			}
+
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// skipByteOrderMark drops the UTF-8 BOM starting CSV files exported by Excel,
+// which would otherwise be part of the first column name
+func skipByteOrderMark(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\ufeff")) {
+		br.Discard(3)
+	}
+
+	return br
+}
+
+func bindCSVRecord[T any](record, header []string, columns []*boundField, row int) (T, error) {
+	var v T
+
+	if columns == nil {
+		return any(append([]string(nil), record...)).(T), nil
+	}
+
+	rv := reflect.ValueOf(&v).Elem()
+
+	for i, value := range record {
+		field := columns[i]
+		if field == nil || value == "" {
+			continue
+		}
+
+		p := Param{
+			from:  paramFrom(fmt.Sprintf("CSV row %d, column %d", row, i+1)),
+			name:  header[i],
+			value: value,
+		}
+
+		if err := p.bind(rv.FieldByIndex(field.index), field.format); err != nil {
+			var httpErr *Error
+			if !errors.As(err, &httpErr) {
+				// Unsupported field types are programming errors
+				return v, err
+			}
+
+			return v, csvRowError(row, i+1, header[i], httpErr)
+		}
+	}
+
+	if err := verifyValidator(v); err != nil {
+		return v, csvRowError(row, 0, "", err)
+	}
+
+	return v, nil
+}
+
+// csvRowError ties err to a row, and a column when known
+func csvRowError(row, column int, header string, err error) *Error {
+	return itemError("invalid CSV row", err, func(errMessage string, errDetails any) any {
+		return CSVErrorDetails{Row: row, Column: column, Header: header, Error: errMessage, Details: errDetails}
+	})
+}
+
+func csvReadError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return NewError(http.StatusBadRequest, "invalid CSV body", WithDetails(CSVErrorDetails{
+			Row:    parseErr.StartLine,
+			Column: parseErr.Column,
+			Error:  parseErr.Err.Error(),
+		}))
+	}
+
+	return readError(err, "unable to read body")
+}
@@ -0,0 +1,222 @@
+package httpbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type csvUser struct {
+	Name     string    `csv:"name"`
+	Age      int       `csv:"age"`
+	Joined   time.Time `csv:"joined" format:"2006-01-02"`
+	Nickname *string   `csv:"nickname"`
+	Password string    `csv:"-"`
+}
+
+func (u csvUser) Validate() error {
+	if u.Name == "" {
+		return NewError(http.StatusUnprocessableEntity, "name is required")
+	}
+	return nil
+}
+
+func TestWriteCSV(t *testing.T) {
+	nickname := "Johnny"
+	users := []csvUser{
+		{Name: "John", Age: 42, Joined: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Nickname: &nickname, Password: "secret"},
+		{Name: "Doe, Jane", Age: 7, Joined: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := WriteCSV(w, r, http.StatusOK, slices.Values(users))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "name,age,joined,nickname\nJohn,42,2024-01-02,Johnny\n\"Doe, Jane\",7,2023-12-31,\n", w.Body.String())
+}
+
+func TestWriteCSV_Strings(t *testing.T) {
+	rows := [][]string{{"a", "1"}, {"b", "2"}}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := WriteCSV(w, r, http.StatusOK, slices.Values(rows), WithCSVHeader("letter", "number"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "letter,number\na,1\nb,2\n", w.Body.String())
+}
+
+func TestWriteCSV_TSV(t *testing.T) {
+	rows := [][]string{{"a", "1"}}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := WriteCSV(w, r, http.StatusOK, slices.Values(rows), WithTSV(), WithCSVFilename("export.tsv"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "text/tab-separated-values; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=export.tsv`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "a\t1\n", w.Body.String())
+}
+
+func TestWriteCSV_UnsupportedType(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := WriteCSV(w, r, http.StatusOK, slices.Values([]int{1, 2}))
+
+	require.Error(t, err)
+	assert.Zero(t, w.Body.Len())
+}
+
+func TestReadCSV(t *testing.T) {
+	body := "age,name,unknown,nickname,joined\n42,John,x,Johnny,2024-01-02\n7,Jane,,,\n"
+
+	var users []csvUser
+	for user, err := range ReadCSV[csvUser](strings.NewReader(body)) {
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+
+	require.Len(t, users, 2)
+
+	assert.Equal(t, "John", users[0].Name)
+	assert.Equal(t, 42, users[0].Age)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), users[0].Joined)
+	require.NotNil(t, users[0].Nickname)
+	assert.Equal(t, "Johnny", *users[0].Nickname)
+
+	assert.Equal(t, "Jane", users[1].Name)
+	assert.Equal(t, 7, users[1].Age)
+	assert.True(t, users[1].Joined.IsZero())
+	assert.Nil(t, users[1].Nickname)
+}
+
+func TestReadCSV_ByteOrderMark(t *testing.T) {
+	body := "\ufeffname,age\nJohn,42\n"
+
+	var users []csvUser
+	for user, err := range ReadCSV[csvUser](strings.NewReader(body)) {
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+
+	require.Len(t, users, 1)
+	assert.Equal(t, "John", users[0].Name)
+	assert.Equal(t, 42, users[0].Age)
+
+	users = nil
+	for user, err := range ReadCSV[csvUser](strings.NewReader("\ufeffJane,7\n"), WithCSVHeader("name", "age")) {
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+
+	require.Len(t, users, 1)
+	assert.Equal(t, "Jane", users[0].Name)
+}
+
+func TestReadCSV_Errors(t *testing.T) {
+	body := "name,age\nJohn,abc\n,7\nDoe,3\nJane\n"
+
+	var names []string
+	var errs []*Error
+
+	for user, err := range ReadCSV[csvUser](strings.NewReader(body)) {
+		if err != nil {
+			var httpErr *Error
+			require.True(t, errors.As(err, &httpErr))
+			errs = append(errs, httpErr)
+			// Neither partially bound nor previous rows are yielded with errors
+			assert.Zero(t, user)
+			continue
+		}
+		names = append(names, user.Name)
+	}
+
+	assert.Equal(t, []string{"Doe"}, names)
+	require.Len(t, errs, 3)
+
+	assert.Equal(t, http.StatusBadRequest, errs[0].Code)
+	assert.Equal(t, `parameter "age" from CSV row 2, column 2 must be an integer`, errs[0].Message)
+	assert.Equal(t, CSVErrorDetails{Row: 2, Column: 2, Header: "age", Error: errs[0].Message}, errs[0].Details)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, errs[1].Code)
+	assert.Equal(t, CSVErrorDetails{Row: 3, Error: "name is required"}, errs[1].Details)
+
+	assert.Equal(t, http.StatusBadRequest, errs[2].Code)
+	assert.Equal(t, "invalid CSV row", errs[2].Message)
+	assert.Equal(t, 5, errs[2].Details.(CSVErrorDetails).Row)
+}
+
+func TestReadCSV_Malformed(t *testing.T) {
+	body := "name,age\nJohn,42\n\"Jane,7\nDoe,3\n"
+
+	var names []string
+	var lastErr error
+
+	for user, err := range ReadCSV[csvUser](strings.NewReader(body)) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		names = append(names, user.Name)
+	}
+
+	assert.Equal(t, []string{"John"}, names)
+
+	var httpErr *Error
+	require.True(t, errors.As(lastErr, &httpErr))
+	assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+	assert.Equal(t, "invalid CSV body", httpErr.Message)
+	assert.Equal(t, 3, httpErr.Details.(CSVErrorDetails).Row)
+}
+
+func TestReadCSV_WithHeader(t *testing.T) {
+	body := "John\t42\nJane\t7\n"
+
+	var users []csvUser
+	for user, err := range ReadCSV[csvUser](strings.NewReader(body), WithTSV(), WithCSVHeader("name", "age")) {
+		require.NoError(t, err)
+		users = append(users, user)
+	}
+
+	require.Len(t, users, 2)
+	assert.Equal(t, "John", users[0].Name)
+	assert.Equal(t, 42, users[0].Age)
+	assert.Equal(t, "Jane", users[1].Name)
+}
+
+func TestReadCSV_Strings(t *testing.T) {
+	body := "letter,number\na,1\nb,2\n"
+
+	var rows [][]string
+	for row, err := range ReadCSV[[]string](strings.NewReader(body)) {
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+
+	assert.Equal(t, [][]string{{"a", "1"}, {"b", "2"}}, rows)
+}
+
+func TestReadCSV_Empty(t *testing.T) {
+	count := 0
+	for range ReadCSV[csvUser](strings.NewReader("")) {
+		count++
+	}
+
+	assert.Zero(t, count)
+}
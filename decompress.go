@@ -0,0 +1,172 @@
+package httpbox
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const DefaultDecompressionLimit = 10 << 20
+
+type decompressionConfig struct {
+	limit         int64
+	decompressors map[string]func(io.Reader) (io.ReadCloser, error)
+}
+
+type DecompressionOption func(*decompressionConfig)
+
+// WithDecompressionLimit sets the maximum size of decompressed bodies. Reading
+// beyond it fails with a 413 error, protecting against decompression bombs
+func WithDecompressionLimit(limit int64) DecompressionOption {
+	return func(cfg *decompressionConfig) {
+		cfg.limit = limit
+	}
+}
+
+// WithDecompressor registers an additional content coding
+func WithDecompressor(encoding string, newReader func(io.Reader) (io.ReadCloser, error)) DecompressionOption {
+	return func(cfg *decompressionConfig) {
+		cfg.decompressors[strings.ToLower(encoding)] = newReader
+	}
+}
+
+// DecompressionMiddleware decodes request bodies sent with a Content-Encoding,
+// so that handlers and ReadJSON see the original body. Requests using an
+// unsupported encoding are rejected with 415 errors
+func DecompressionMiddleware(opts ...DecompressionOption) Middleware {
+	cfg := &decompressionConfig{
+		limit: DefaultDecompressionLimit,
+		decompressors: map[string]func(io.Reader) (io.ReadCloser, error){
+			"gzip":    newGzipReader,
+			"x-gzip":  newGzipReader,
+			"deflate": newDeflateReader,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			encodings := parseHeaderList(r.Header.Values("Content-Encoding"))
+			if len(encodings) == 0 || r.Body == nil || r.Body == http.NoBody {
+				return h(w, r)
+			}
+
+			body := io.ReadCloser(r.Body)
+
+			// Encodings are listed in the order they were applied
+			for i := len(encodings) - 1; i >= 0; i-- {
+				encoding := encodings[i]
+				if encoding == "identity" {
+					continue
+				}
+
+				newReader, ok := cfg.decompressors[encoding]
+				if !ok {
+					w.Header().Set("Accept-Encoding", cfg.supported())
+					return NewError(http.StatusUnsupportedMediaType, "unsupported content encoding", WithDetails(encoding))
+				}
+
+				reader, err := newReader(body)
+				if err != nil {
+					return NewError(http.StatusBadRequest, "invalid compressed body", WithDetails(err.Error()))
+				}
+
+				body = reader
+			}
+
+			r2 := r.Clone(r.Context())
+			r2.Body = &limitedBody{limitedReader: newLimitedReader(body, cfg.limit), decompressed: body, original: r.Body}
+			r2.ContentLength = -1
+			r2.Header.Del("Content-Encoding")
+			r2.Header.Del("Content-Length")
+
+			return h(w, r2)
+		}
+	}
+}
+
+func (cfg *decompressionConfig) supported() string {
+	encodings := make([]string, 0, len(cfg.decompressors))
+	for encoding := range cfg.decompressors {
+		encodings = append(encodings, encoding)
+	}
+
+	slices.Sort(encodings)
+
+	return strings.Join(encodings, ", ")
+}
+
+func newGzipReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// newDeflateReader accepts both zlib streams, as required by the deflate
+// coding, and raw deflate streams, which some clients send instead
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+// limitedReader fails with a 413 error once more than remaining bytes are read
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func newLimitedReader(r io.Reader, limit int64) *limitedReader {
+	return &limitedReader{r: r, remaining: limit}
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.remaining < 0 {
+		return 0, lr.tooLarge()
+	}
+
+	// Reading one byte past the limit tells bodies of exactly the limit apart
+	if int64(len(p)) > lr.remaining+1 {
+		p = p[:lr.remaining+1]
+	}
+
+	n, err := lr.r.Read(p)
+	lr.remaining -= int64(n)
+
+	if lr.remaining < 0 {
+		return n + int(lr.remaining), lr.tooLarge()
+	}
+
+	return n, err
+}
+
+func (lr *limitedReader) tooLarge() error {
+	return NewError(http.StatusRequestEntityTooLarge, "request body too large")
+}
+
+// limitedBody is a decompressed body, closed along with the original one
+type limitedBody struct {
+	*limitedReader
+	decompressed io.Closer
+	original     io.Closer
+}
+
+func (lb *limitedBody) Close() error {
+	lb.decompressed.Close()
+	return lb.original.Close()
+}
@@ -0,0 +1,171 @@
+package httpbox
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	return buf.Bytes()
+}
+
+func zlibBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := zw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+func rawDeflateBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, err = fw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, fw.Close())
+
+	return buf.Bytes()
+}
+
+func jsonEchoHandler() Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		body, err := ReadJSON[testStruct](r.Body)
+		if err != nil {
+			return err
+		}
+
+		return WriteJSON(w, http.StatusOK, body)
+	}
+}
+
+func serveDecompressed(h Handler, encoding string, body []byte, opts ...DecompressionOption) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/test", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	rec := httptest.NewRecorder()
+	h.WithMiddlewares(DecompressionMiddleware(opts...)).ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestDecompressionMiddleware_Decodes(t *testing.T) {
+	payload := `{"name":"John","email":"john@example.com","age":30}`
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"identity", "", []byte(payload)},
+		{"gzip", "gzip", gzipBytes(t, payload)},
+		{"uppercase", "GZIP", gzipBytes(t, payload)},
+		{"zlib deflate", "deflate", zlibBytes(t, payload)},
+		{"raw deflate", "deflate", rawDeflateBytes(t, payload)},
+		{"several encodings", "deflate, gzip", gzipBytes(t, string(zlibBytes(t, payload)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveDecompressed(jsonEchoHandler(), tt.encoding, tt.body)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, payload, rec.Body.String())
+		})
+	}
+}
+
+func TestDecompressionMiddleware_Headers(t *testing.T) {
+	var seen *http.Request
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		seen = r
+		return nil
+	})
+
+	serveDecompressed(h, "gzip", gzipBytes(t, "hello"))
+
+	require.NotNil(t, seen)
+	assert.Empty(t, seen.Header.Get("Content-Encoding"))
+	assert.Equal(t, int64(-1), seen.ContentLength)
+}
+
+func TestDecompressionMiddleware_UnsupportedEncoding(t *testing.T) {
+	rec := serveDecompressed(jsonEchoHandler(), "br", []byte("data"))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Equal(t, "deflate, gzip, x-gzip", rec.Header().Get("Accept-Encoding"))
+	assert.JSONEq(t, `{"code":415,"message":"unsupported content encoding","details":"br"}`, rec.Body.String())
+}
+
+func TestDecompressionMiddleware_InvalidBody(t *testing.T) {
+	rec := serveDecompressed(jsonEchoHandler(), "gzip", []byte("not gzip"))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDecompressionMiddleware_Limit(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         int
+		expectedCode int
+	}{
+		{"below limit", 99, http.StatusOK},
+		{"at limit", 100, http.StatusOK},
+		{"above limit", 101, http.StatusRequestEntityTooLarge},
+		{"bomb", 10 << 20, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				if _, err := ReadBytes(r.Body); err != nil {
+					return err
+				}
+				return nil
+			})
+
+			rec := serveDecompressed(h, "gzip", gzipBytes(t, strings.Repeat("a", tt.size)), WithDecompressionLimit(100))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestDecompressionMiddleware_CustomDecompressor(t *testing.T) {
+	payload := `{"name":"John"}`
+
+	rec := serveDecompressed(jsonEchoHandler(), "x-test", []byte(payload),
+		WithDecompressor("x-test", func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		}),
+	)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -1,11 +1,12 @@
 package httpbox
 
 type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Details any    `json:"details,omitempty"`
-	Err     error  `json:"-"`
-	Log     bool   `json:"-"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Err       error  `json:"-"`
+	Log       bool   `json:"-"`
 }
 
 type ErrorOption func(*Error)
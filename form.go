@@ -0,0 +1,124 @@
+package httpbox
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// DefaultFormMaxSize is the size limit of URL-encoded form bodies, the one of
+// Request.ParseForm
+const DefaultFormMaxSize = 10 << 20
+
+// ReadForm binds the values of an application/x-www-form-urlencoded or
+// multipart/form-data body, and of the URL query string, into a struct. Fields
+// are matched with values through their form tags or names, and converted
+// following the same rules as Param. Slice fields receive every value of their
+// name.
+//
+// File parts of multipart bodies are skipped, ReadMultipart receives them. The
+// options apply to multipart bodies
+func ReadForm[T any](r *http.Request, opts ...MultipartOption) (T, error) {
+	var v T
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "":
+		return BindForm[T](r.URL.Query())
+
+	case "application/x-www-form-urlencoded":
+		// Read here rather than with ParseForm, whose size limit error cannot
+		// be told apart from malformed bodies
+		data, err := io.ReadAll(newLimitedReader(r.Body, DefaultFormMaxSize))
+		if err != nil {
+			return v, readError(err, "unable to read body")
+		}
+
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return v, NewError(http.StatusBadRequest, "invalid form body", WithDetails(err))
+		}
+
+		return BindForm[T](mergeQuery(values, r))
+
+	case "multipart/form-data":
+		cfg := newMultipartConfig(opts)
+		cfg.discardFiles = true
+
+		form, err := readMultipart(r, cfg)
+		if err != nil {
+			return v, err
+		}
+
+		return BindForm[T](mergeQuery(form.Value, r))
+	}
+
+	return v, NewError(http.StatusUnsupportedMediaType, "unsupported content type", WithDetails(mediaType))
+}
+
+// mergeQuery adds the URL query string values of r after the body values, as
+// Request.ParseForm does
+func mergeQuery(values url.Values, r *http.Request) url.Values {
+	for name, list := range r.URL.Query() {
+		values[name] = append(values[name], list...)
+	}
+
+	return values
+}
+
+// BindForm binds values into a struct, as ReadForm does. It is typically used
+// with the values of a MultipartForm
+func BindForm[T any](values url.Values) (T, error) {
+	var v T
+
+	rv := reflect.ValueOf(&v).Elem()
+	if rv.Kind() != reflect.Struct {
+		return v, errors.New("httpbox: form values must be bound to a struct, got " + rv.Type().String())
+	}
+
+	for _, field := range boundFields(rv.Type(), "form") {
+		list := values[field.name]
+		if len(list) == 0 {
+			continue
+		}
+
+		fv := rv.FieldByIndex(field.index)
+
+		// Slices implementing encoding.TextUnmarshaler, such as net.IP, are
+		// single values
+		if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalType) {
+			fv.Set(reflect.MakeSlice(fv.Type(), len(list), len(list)))
+
+			for i, value := range list {
+				if err := bindFormValue(fv.Index(i), field, value); err != nil {
+					return v, err
+				}
+			}
+			continue
+		}
+
+		if err := bindFormValue(fv, field, list[0]); err != nil {
+			return v, err
+		}
+	}
+
+	if err := verifyValidator(v); err != nil {
+		return v, err
+	}
+
+	return v, nil
+}
+
+func bindFormValue(v reflect.Value, field boundField, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	p := Param{from: fromForm, name: field.name, value: value}
+
+	return p.bind(v, field.format)
+}
@@ -0,0 +1,198 @@
+package httpbox
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signupForm struct {
+	Name   string    `form:"name"`
+	Age    int       `form:"age"`
+	Born   time.Time `form:"born" format:"2006-01-02"`
+	Tags   []string  `form:"tag"`
+	Scores []int     `form:"score"`
+	Page   int       `form:"page"`
+	Secret string    `form:"-"`
+}
+
+func (f signupForm) Validate() error {
+	if f.Name == "" {
+		return NewError(http.StatusUnprocessableEntity, "name is required")
+	}
+	return nil
+}
+
+func TestReadForm(t *testing.T) {
+	body := "name=John&age=42&born=1990-05-17&tag=a&tag=b&score=1&score=2&Secret=x"
+
+	r := httptest.NewRequest(http.MethodPost, "/?page=3", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	form, err := ReadForm[signupForm](r)
+
+	require.NoError(t, err)
+	assert.Equal(t, signupForm{
+		Name:   "John",
+		Age:    42,
+		Born:   time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		Tags:   []string{"a", "b"},
+		Scores: []int{1, 2},
+		Page:   3,
+	}, form)
+}
+
+func TestReadForm_Query(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?name=John&age=", nil)
+
+	form, err := ReadForm[signupForm](r)
+
+	require.NoError(t, err)
+	assert.Equal(t, "John", form.Name)
+	assert.Zero(t, form.Age)
+}
+
+func TestReadForm_Multipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("name", "John"))
+	require.NoError(t, mw.WriteField("tag", "a"))
+	fw, err := mw.CreateFormFile("avatar", "avatar.png")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("skipped"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/?tag=b", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	form, err := ReadForm[signupForm](r)
+
+	require.NoError(t, err)
+	assert.Equal(t, "John", form.Name)
+	assert.Equal(t, []string{"a", "b"}, form.Tags)
+}
+
+func TestReadForm_MultipartOptions(t *testing.T) {
+	r := multipartRequest(t, testPart{field: "name", content: []byte("John")})
+
+	_, err := ReadForm[signupForm](r, WithMultipartMaxValueSize(3))
+
+	var httpErr *Error
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+	assert.Equal(t, "form value too large", httpErr.Message)
+}
+
+func TestReadForm_Errors(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		body            string
+		expectedCode    int
+		expectedMessage string
+	}{
+		{
+			name:            "invalid value",
+			contentType:     "application/x-www-form-urlencoded",
+			body:            "name=John&age=abc",
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: `parameter "age" from form must be an integer`,
+		},
+		{
+			name:            "invalid slice element",
+			contentType:     "application/x-www-form-urlencoded",
+			body:            "name=John&score=1&score=x",
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: `parameter "score" from form must be an integer`,
+		},
+		{
+			name:            "validation",
+			contentType:     "application/x-www-form-urlencoded",
+			body:            "age=42",
+			expectedCode:    http.StatusUnprocessableEntity,
+			expectedMessage: "name is required",
+		},
+		{
+			name:            "malformed body",
+			contentType:     "application/x-www-form-urlencoded",
+			body:            "name=%zz",
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "invalid form body",
+		},
+		{
+			name:            "unsupported content type",
+			contentType:     "application/json",
+			body:            `{"name":"John"}`,
+			expectedCode:    http.StatusUnsupportedMediaType,
+			expectedMessage: "unsupported content type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			_, err := ReadForm[signupForm](r)
+
+			var httpErr *Error
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tt.expectedCode, httpErr.Code)
+			assert.Equal(t, tt.expectedMessage, httpErr.Message)
+		})
+	}
+}
+
+func TestReadForm_TooLarge(t *testing.T) {
+	body := "name=" + strings.Repeat("x", DefaultFormMaxSize)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, err := ReadForm[signupForm](r)
+
+	var httpErr *Error
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+	assert.Equal(t, "request body too large", httpErr.Message)
+}
+
+func TestReadForm_MaxBytesReader(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("name=John&age=42"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 4)
+
+	_, err := ReadForm[signupForm](r)
+
+	var httpErr *Error
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+}
+
+func TestBindForm(t *testing.T) {
+	values := url.Values{"name": {"John", "ignored"}, "tag": {"a"}}
+
+	form, err := BindForm[signupForm](values)
+
+	require.NoError(t, err)
+	assert.Equal(t, "John", form.Name)
+	assert.Equal(t, []string{"a"}, form.Tags)
+}
+
+func TestBindForm_NotStruct(t *testing.T) {
+	_, err := BindForm[map[string]string](url.Values{})
+
+	var httpErr *Error
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &httpErr))
+}
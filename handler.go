@@ -1,6 +1,7 @@
 package httpbox
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -8,44 +9,128 @@ import (
 
 type Handler func(w http.ResponseWriter, r *http.Request) error
 
+// ErrResponseAborted is returned by handlers whose response was committed but
+// cannot be completed. Handler.ServeHTTP then aborts the response with
+// http.ErrAbortHandler, so that clients get a transport error instead of a
+// successful truncated body
+var ErrResponseAborted = errors.New("response aborted")
+
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, state := withRequestState(r)
+	if r.Pattern != "" {
+		state.pattern = r.Pattern
+	}
+
+	// When wrapping a mux, the abort is left to the outermost Handler so that
+	// the middlewares in between still record the request
+	outermost := !state.serving
+	state.serving = true
+
 	err := h(w, r)
 
-	if err != nil {
-		handleError(w, err)
-		return
+	if errors.Is(err, ErrResponseAborted) {
+		state.aborted = true
+	} else if err != nil {
+		handleError(w, r, err)
+	}
+
+	if outermost && state.aborted {
+		panic(http.ErrAbortHandler)
 	}
 }
 
-func handleError(w http.ResponseWriter, err error) {
+// StatusClientClosedRequest is the non standard status used, following nginx,
+// to report requests abandoned by the client before a response was sent
+const StatusClientClosedRequest = 499
+
+// IsClientClosedRequest reports whether err is a context error caused by the
+// client going away, which the server signals by canceling the request
+// context. Deadlines set by middlewares are not considered, as they end in a
+// response, and other errors are real failures even once the client is gone
+func IsClientClosedRequest(r *http.Request, err error) bool {
+	if !errors.Is(r.Context().Err(), context.Canceled) {
+		return false
+	}
+
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// toHTTPError returns the *Error wrapped by err, or a generic 500 error. This
+// avoids leaking internal error details to the client. The library user should
+// wrap errors in httpbox.Error to provide proper status codes and messages
+func toHTTPError(err error) *Error {
 	var httpErr *Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
 
-	// This avoids leaking internal error details to the client. The library user
-	// should wrap errors in httpbox.Error to provide proper status codes and messages
-	if !errors.As(err, &httpErr) {
-		httpErr = NewError(http.StatusInternalServerError, "Unexpected error occurred",
-			WithInternalError(err),
-			WithLog(),
-		)
+	return NewError(http.StatusInternalServerError, "Unexpected error occurred",
+		WithInternalError(err),
+		WithLog(),
+	)
+}
+
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	// Nobody is left to read the response, and the error is just the handler
+	// noticing it. AccessLogMiddleware and MetricsMiddleware report these as 499
+	if IsClientClosedRequest(r, err) {
+		if state := requestStateFrom(r.Context()); state != nil {
+			state.clientClosed = true
+		}
+
+		slog.Debug("Client closed request", append(logAttrs(r.Context()), "error", err)...)
+		return
 	}
 
-	// The only possible error is if the Details field contains non-serializable data
-	if err := WriteJSON(w, httpErr.Code, httpErr); err != nil {
+	httpErr := requestError(r, err)
+
+	if !bodyAllowedForStatus(httpErr.Code) {
+		// Such as the 304 errors of CheckPreconditions
+		w.WriteHeader(httpErr.Code)
+	} else if err := WriteJSON(w, httpErr.Code, httpErr); err != nil {
+		// The only possible error is if the Details field contains non-serializable data
 		failedMsg := "failed to serialize error details"
 
 		httpErr.Details = failedMsg
 
-		slog.Error(failedMsg, "error", err, "original_error", httpErr.Err)
+		slog.Error(failedMsg, append(logAttrs(r.Context()), "error", err, "original_error", httpErr.Err)...)
 
 		// Since we overwrite Details, we ignore the error here as it will not occur
 		WriteJSON(w, httpErr.Code, httpErr)
 	}
 
+	logError(r, httpErr)
+}
+
+// requestError converts err into the *Error sent to the client
+func requestError(r *http.Request, err error) *Error {
+	httpErr := toHTTPError(err)
+
+	if state := requestStateFrom(r.Context()); state != nil && state.requestIDInError {
+		// Errors may be shared package level values, so they are copied before
+		// being tied to a specific request
+		withID := *httpErr
+		withID.RequestID = state.requestID
+		httpErr = &withID
+	}
+
+	return httpErr
+}
+
+func logError(r *http.Request, httpErr *Error) {
 	if httpErr.Log {
-		slog.Error(httpErr.Message, "code", httpErr.Code, "details", httpErr.Details, "error", httpErr.Err)
+		slog.Error(httpErr.Message, append(logAttrs(r.Context()),
+			"code", httpErr.Code,
+			"details", httpErr.Details,
+			"error", httpErr.Err,
+		)...)
 	}
 }
 
+func bodyAllowedForStatus(code int) bool {
+	return code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
 func (h Handler) WithMiddlewares(middlewares ...Middleware) Handler {
 	return applyMiddlewares(h, middlewares...)
 }
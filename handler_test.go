@@ -1,7 +1,9 @@
 package httpbox
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -74,3 +76,61 @@ func TestHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_ClientClosedRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+		expectedLog  string
+	}{
+		{"context error", context.Canceled, http.StatusOK, ""},
+		{"wrapped context error", fmt.Errorf("query failed: %w", context.Canceled), http.StatusOK, ""},
+		{"logged httpbox error", NewError(http.StatusServiceUnavailable, "query canceled", WithInternalError(context.Canceled), WithLog()), http.StatusServiceUnavailable, "query canceled"},
+		{"httpbox error", NewError(http.StatusBadRequest, "bad request"), http.StatusBadRequest, ""},
+		{"unexpected error", errors.New("disk full"), http.StatusInternalServerError, "disk full"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+
+			Handler(func(w http.ResponseWriter, r *http.Request) error {
+				return tt.err
+			}).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.Empty(t, rec.Body.String())
+			}
+
+			if tt.expectedLog != "" {
+				assert.Contains(t, logs.String(), tt.expectedLog)
+			} else {
+				assert.NotContains(t, logs.String(), "level=ERROR")
+			}
+		})
+	}
+}
+
+func TestIsClientClosedRequest(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), 0)
+	defer cancelExpired()
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+
+	assert.True(t, IsClientClosedRequest(req.WithContext(canceled), context.Canceled))
+	assert.False(t, IsClientClosedRequest(req.WithContext(canceled), nil))
+	assert.False(t, IsClientClosedRequest(req.WithContext(expired), context.DeadlineExceeded))
+	assert.False(t, IsClientClosedRequest(req, context.Canceled))
+	assert.False(t, IsClientClosedRequest(req.WithContext(canceled), errors.New("disk full")))
+}
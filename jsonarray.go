@@ -0,0 +1,357 @@
+package httpbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type jsonArrayConfig struct {
+	field         string
+	meta          map[string]any
+	flushEvery    int
+	flushInterval time.Duration
+}
+
+type JSONArrayOption func(*jsonArrayConfig)
+
+// WithJSONArrayEnvelope wraps the array in an object, under field, along with
+// the meta fields. Errors occurring once the response is committed are then
+// reported in an "error" field of the object
+func WithJSONArrayEnvelope(field string, meta map[string]any) JSONArrayOption {
+	return func(cfg *jsonArrayConfig) {
+		cfg.field = field
+		cfg.meta = meta
+	}
+}
+
+// WithJSONArrayFlush flushes the response every n elements, or when interval
+// elapsed since the last flush
+func WithJSONArrayFlush(n int, interval time.Duration) JSONArrayOption {
+	return func(cfg *jsonArrayConfig) {
+		cfg.flushEvery = n
+		cfg.flushInterval = interval
+	}
+}
+
+// ItemsFromSeq adapts a sequence that cannot fail to WriteJSONArray
+func ItemsFromSeq[T any](seq iter.Seq[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for item := range seq {
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ItemsFromChan adapts a channel to WriteJSONArray. The sequence ends when ch
+// is closed
+func ItemsFromChan[T any](ch <-chan T) iter.Seq2[T, error] {
+	return ItemsFromSeq(func(yield func(T) bool) {
+		for item := range ch {
+			if !yield(item) {
+				return
+			}
+		}
+	})
+}
+
+// WriteJSONArray streams items as a JSON array without holding them in memory.
+// The status is sent along with the first element, so an error yielded before
+// it is returned as is. Afterwards, the error is logged and the response is
+// terminated in a way clients can detect: with an "error" field when using an
+// envelope, or otherwise by returning ErrResponseAborted once the elements
+// written so far are flushed
+func WriteJSONArray[T any](w http.ResponseWriter, r *http.Request, code int, items iter.Seq2[T, error], opts ...JSONArrayOption) error {
+	cfg := &jsonArrayConfig{flushEvery: 100, flushInterval: time.Second}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	aw := &jsonArrayWriter{w: w, rc: http.NewResponseController(w), cfg: cfg, code: code, lastFlush: time.Now()}
+
+	for item, err := range items {
+		if err == nil {
+			err = r.Context().Err()
+		}
+
+		if err != nil {
+			return aw.fail(r, err)
+		}
+
+		data, err := json.Marshal(item)
+		if err != nil {
+			return aw.fail(r, err)
+		}
+
+		if err := aw.writeElement(data); err != nil {
+			return err
+		}
+	}
+
+	return aw.close()
+}
+
+type jsonArrayWriter struct {
+	w    http.ResponseWriter
+	rc   *http.ResponseController
+	cfg  *jsonArrayConfig
+	code int
+
+	started   bool
+	count     int
+	pending   int
+	lastFlush time.Time
+}
+
+func (aw *jsonArrayWriter) start() error {
+	prefix := []byte("[")
+
+	if aw.cfg.field != "" {
+		prefix = []byte("{")
+
+		for _, key := range slices.Sorted(maps.Keys(aw.cfg.meta)) {
+			value, err := json.Marshal(aw.cfg.meta[key])
+			if err != nil {
+				return err
+			}
+
+			prefix = appendJSONKey(prefix, key)
+			prefix = append(prefix, value...)
+			prefix = append(prefix, ',')
+		}
+
+		prefix = appendJSONKey(prefix, aw.cfg.field)
+		prefix = append(prefix, '[')
+	}
+
+	aw.started = true
+
+	aw.w.Header().Set("Content-Type", "application/json")
+	aw.w.WriteHeader(aw.code)
+
+	_, err := aw.w.Write(prefix)
+	return err
+}
+
+func appendJSONKey(b []byte, key string) []byte {
+	encoded, _ := json.Marshal(key)
+	b = append(b, encoded...)
+	return append(b, ':')
+}
+
+func (aw *jsonArrayWriter) writeElement(data []byte) error {
+	if !aw.started {
+		if err := aw.start(); err != nil {
+			return err
+		}
+	}
+
+	if aw.count > 0 {
+		data = append([]byte(","), data...)
+	}
+
+	if _, err := aw.w.Write(data); err != nil {
+		return err
+	}
+
+	aw.count++
+	aw.pending++
+
+	if aw.pending >= aw.cfg.flushEvery || time.Since(aw.lastFlush) >= aw.cfg.flushInterval {
+		return aw.flush()
+	}
+
+	return nil
+}
+
+func (aw *jsonArrayWriter) flush() error {
+	aw.pending = 0
+	aw.lastFlush = time.Now()
+
+	if err := aw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	return nil
+}
+
+func (aw *jsonArrayWriter) close() error {
+	if !aw.started {
+		if err := aw.start(); err != nil {
+			return err
+		}
+	}
+
+	suffix := "]"
+	if aw.cfg.field != "" {
+		suffix = "]}"
+	}
+
+	if _, err := aw.w.Write([]byte(suffix)); err != nil {
+		return err
+	}
+
+	return aw.flush()
+}
+
+func (aw *jsonArrayWriter) fail(r *http.Request, err error) error {
+	if !aw.started || IsClientClosedRequest(r, err) {
+		return err
+	}
+
+	httpErr := requestError(r, err)
+	logError(r, httpErr)
+
+	if aw.cfg.field == "" {
+		aw.flush()
+		return fmt.Errorf("%w: %w", ErrResponseAborted, err)
+	}
+
+	data, marshalErr := json.Marshal(httpErr)
+	if marshalErr != nil {
+		data, _ = json.Marshal(NewError(httpErr.Code, httpErr.Message))
+	}
+
+	trailer := append([]byte(`],"error":`), data...)
+	trailer = append(trailer, '}')
+
+	if _, err := aw.w.Write(trailer); err != nil {
+		return err
+	}
+
+	return aw.flush()
+}
+
+type ElementErrorDetails struct {
+	Index   int    `json:"index"`
+	Error   string `json:"error"`
+	Details any    `json:"details,omitempty"`
+}
+
+// ReadJSONArray decodes a JSON array body one element at a time, so that large
+// bodies are not held in memory. Each invalid element yields an error holding
+// its index. Malformed JSON ends the iteration
+func ReadJSONArray[T any](r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		dec := json.NewDecoder(r)
+
+		if err := expectJSONDelim(dec, '['); err != nil {
+			var v T
+			yield(v, err)
+			return
+		}
+
+		readJSONArrayElements(dec, yield)
+	}
+}
+
+// ReadJSONArrayField decodes the elements of the array held by field in a JSON
+// object body, as ReadJSONArray does. The other fields are skipped
+func ReadJSONArrayField[T any](r io.Reader, field string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var v T
+		dec := json.NewDecoder(r)
+
+		if err := expectJSONDelim(dec, '{'); err != nil {
+			yield(v, err)
+			return
+		}
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				yield(v, readError(err, "invalid JSON body"))
+				return
+			}
+
+			if key != field {
+				var skipped json.RawMessage
+				if err := dec.Decode(&skipped); err != nil {
+					yield(v, readError(err, "invalid JSON body"))
+					return
+				}
+				continue
+			}
+
+			if err := expectJSONDelim(dec, '['); err != nil {
+				yield(v, err)
+				return
+			}
+
+			readJSONArrayElements(dec, yield)
+			return
+		}
+
+		yield(v, NewError(http.StatusBadRequest, "invalid JSON body", WithDetails(fmt.Sprintf("missing %q array", field))))
+	}
+}
+
+func expectJSONDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return readError(err, "invalid JSON body")
+	}
+
+	if token != delim {
+		expected := "an array"
+		if delim == '{' {
+			expected = "an object"
+		}
+
+		return NewError(http.StatusBadRequest, "invalid JSON body", WithDetails("expected "+expected))
+	}
+
+	return nil
+}
+
+func readJSONArrayElements[T any](dec *json.Decoder, yield func(T, error) bool) {
+	for index := 0; dec.More(); index++ {
+		var v T
+
+		// Elements are first read as raw values, so that an element not matching
+		// T does not prevent decoding the next ones
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			yield(v, elementError(index, err))
+			return
+		}
+
+		if err := json.Unmarshal(raw, &v); err != nil {
+			if !yield(v, elementError(index, err)) {
+				return
+			}
+			continue
+		}
+
+		if err := verifyValidator(v); err != nil {
+			if !yield(v, elementError(index, err)) {
+				return
+			}
+			continue
+		}
+
+		if !yield(v, nil) {
+			return
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		var v T
+		yield(v, readError(err, "invalid JSON body"))
+	}
+}
+
+func elementError(index int, err error) *Error {
+	return itemError("invalid array element", err, func(errMessage string, errDetails any) any {
+		return ElementErrorDetails{Index: index, Error: errMessage, Details: errDetails}
+	})
+}
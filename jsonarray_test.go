@@ -0,0 +1,325 @@
+package httpbox
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingItems yields the given items, then fails with err
+func failingItems(items []int, err error) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		for _, item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+		yield(0, err)
+	}
+}
+
+func serveJSONArray(items iter.Seq2[int, error], opts ...JSONArrayOption) *httptest.ResponseRecorder {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WriteJSONArray(w, r, http.StatusOK, items, opts...)
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	return rec
+}
+
+func TestWriteJSONArray(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    iter.Seq2[int, error]
+		opts     []JSONArrayOption
+		expected string
+	}{
+		{"items", ItemsFromSeq(slices.Values([]int{1, 2, 3})), nil, `[1,2,3]`},
+		{"empty", ItemsFromSeq(slices.Values([]int{})), nil, `[]`},
+		{
+			"envelope",
+			ItemsFromSeq(slices.Values([]int{1, 2})),
+			[]JSONArrayOption{WithJSONArrayEnvelope("items", map[string]any{"page": 1, "cursor": "abc"})},
+			`{"cursor":"abc","page":1,"items":[1,2]}`,
+		},
+		{
+			"empty envelope",
+			ItemsFromSeq(slices.Values([]int{})),
+			[]JSONArrayOption{WithJSONArrayEnvelope("items", nil)},
+			`{"items":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveJSONArray(tt.items, tt.opts...)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expected, rec.Body.String())
+		})
+	}
+}
+
+func TestWriteJSONArray_Channel(t *testing.T) {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := range 3 {
+			ch <- i
+		}
+	}()
+
+	rec := serveJSONArray(ItemsFromChan(ch))
+
+	assert.Equal(t, `[0,1,2]`, rec.Body.String())
+}
+
+func TestWriteJSONArray_Flush(t *testing.T) {
+	flushes := 0
+	w := &flushCounter{ResponseRecorder: httptest.NewRecorder(), flushes: &flushes}
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	err := WriteJSONArray(w, req, http.StatusOK, ItemsFromSeq(slices.Values([]int{1, 2, 3, 4, 5})), WithJSONArrayFlush(2, time.Hour))
+
+	require.NoError(t, err)
+	// After the 2nd and 4th elements, and at the end
+	assert.Equal(t, 3, flushes)
+}
+
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushes *int
+}
+
+func (fc *flushCounter) Flush() {
+	*fc.flushes++
+	fc.ResponseRecorder.Flush()
+}
+
+func TestWriteJSONArray_ErrorBeforeFirstElement(t *testing.T) {
+	rec := serveJSONArray(failingItems(nil, NewError(http.StatusServiceUnavailable, "database unavailable")))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"code":503,"message":"database unavailable"}`, rec.Body.String())
+}
+
+func TestWriteJSONArray_ErrorMidStream(t *testing.T) {
+	logs := captureLogs(t)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	err := WriteJSONArray(rec, req, http.StatusOK, failingItems([]int{1, 2}, errors.New("connection reset")))
+
+	assert.ErrorIs(t, err, ErrResponseAborted)
+	assert.Equal(t, `[1,2`, rec.Body.String())
+	assert.True(t, rec.Flushed)
+	assert.Contains(t, logs.String(), "connection reset")
+}
+
+func TestWriteJSONArray_ErrorMidStreamRecorded(t *testing.T) {
+	handler := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WriteJSONArray(w, r, http.StatusOK, failingItems([]int{1, 2}, errors.New("connection reset")))
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /items", handler)
+
+	tests := []struct {
+		name    string
+		handler Handler
+		route   string
+	}{
+		{"handler", handler, UnmatchedRoute},
+		{"through mux", AdaptHandler(mux), "GET /items"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			reg := NewRegistry()
+
+			h := tt.handler.WithMiddlewares(AccessLogMiddleware(), MetricsMiddleware(reg))
+
+			// The response is aborted once the middlewares recorded it
+			assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+			})
+
+			assert.Contains(t, logs.String(), "res.status=200 res.body_size=4")
+			assert.Contains(t, renderMetrics(t, reg), `http_requests_total{method="GET",route="`+tt.route+`",status="200"} 1`)
+		})
+	}
+}
+
+func TestWriteJSONArray_ErrorMidStreamAbortsResponse(t *testing.T) {
+	captureLogs(t)
+
+	srv := httptest.NewServer(Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WriteJSONArray(w, r, http.StatusOK, failingItems([]int{1, 2}, errors.New("connection reset")))
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	_, err = io.ReadAll(res.Body)
+	assert.Error(t, err)
+}
+
+func TestWriteJSONArray_ErrorMidStreamEnvelope(t *testing.T) {
+	rec := serveJSONArray(
+		failingItems([]int{1, 2}, NewError(http.StatusGatewayTimeout, "query timed out")),
+		WithJSONArrayEnvelope("items", nil),
+	)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"items":[1,2],"error":{"code":504,"message":"query timed out"}}`, rec.Body.String())
+}
+
+func TestReadJSONArray(t *testing.T) {
+	body := `[{"name":"a"}, {"name":"b"} ,{"name":"c"}]`
+
+	var names []string
+	for item, err := range ReadJSONArray[lineItem](strings.NewReader(body)) {
+		require.NoError(t, err)
+		names = append(names, item.Name)
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
+
+func TestReadJSONArray_ElementErrors(t *testing.T) {
+	body := `[{"name":"a"}, {"name":42}, {"name":""}, {"name":"d"}]`
+
+	var names []string
+	var errs []*Error
+
+	for item, err := range ReadJSONArray[lineItem](strings.NewReader(body)) {
+		if err != nil {
+			var httpErr *Error
+			require.True(t, errors.As(err, &httpErr))
+			errs = append(errs, httpErr)
+			continue
+		}
+		names = append(names, item.Name)
+	}
+
+	assert.Equal(t, []string{"a", "d"}, names)
+	require.Len(t, errs, 2)
+
+	assert.Equal(t, http.StatusBadRequest, errs[0].Code)
+	assert.Equal(t, 1, errs[0].Details.(ElementErrorDetails).Index)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, errs[1].Code)
+	assert.Equal(t, ElementErrorDetails{Index: 2, Error: "name is required"}, errs[1].Details)
+}
+
+func TestReadJSONArray_Malformed(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		expectedItems int
+		expectedError string
+	}{
+		{"not an array", `{"name":"a"}`, 0, `"expected an array"`},
+		{"empty body", ``, 0, ``},
+		{"syntax error in element", `[{"name":"a"}, {"name":}]`, 1, `"index":1`},
+		{"unterminated", `[{"name":"a"}`, 1, ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := 0
+			var lastErr error
+
+			for _, err := range ReadJSONArray[lineItem](strings.NewReader(tt.body)) {
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				items++
+			}
+
+			assert.Equal(t, tt.expectedItems, items)
+
+			var httpErr *Error
+			require.True(t, errors.As(lastErr, &httpErr))
+			assert.Equal(t, http.StatusBadRequest, httpErr.Code)
+
+			details, err := json.Marshal(httpErr.Details)
+			require.NoError(t, err)
+			assert.Contains(t, string(details), tt.expectedError)
+		})
+	}
+}
+
+func TestReadJSONArrayField(t *testing.T) {
+	body := `{"meta":{"nested":[1,2]},"items":[{"name":"a"},{"name":"b"}],"after":true}`
+
+	var names []string
+	for item, err := range ReadJSONArrayField[lineItem](strings.NewReader(body), "items") {
+		require.NoError(t, err)
+		names = append(names, item.Name)
+	}
+
+	assert.Equal(t, []string{"a", "b"}, names)
+}
+
+func TestReadJSONArrayField_Missing(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		expectedDetails string
+	}{
+		{"missing field", `{"other":[]}`, `missing "items" array`},
+		{"not an array", `{"items":{}}`, "expected an array"},
+		{"not an object", `[]`, "expected an object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []error
+			for _, err := range ReadJSONArrayField[lineItem](strings.NewReader(tt.body), "items") {
+				errs = append(errs, err)
+			}
+
+			require.Len(t, errs, 1)
+
+			var httpErr *Error
+			require.True(t, errors.As(errs[0], &httpErr))
+			assert.Equal(t, tt.expectedDetails, httpErr.Details)
+		})
+	}
+}
+
+func TestReadJSONArray_BodyLimit(t *testing.T) {
+	body := `[` + strings.Repeat(`{"name":"a"},`, 100) + `{"name":"a"}]`
+
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		for _, err := range ReadJSONArray[lineItem](r.Body) {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	rec := serveDecompressed(h, "gzip", gzipBytes(t, body), WithDecompressionLimit(100))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
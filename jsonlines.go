@@ -0,0 +1,149 @@
+package httpbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+)
+
+const DefaultMaxLineSize = 1 << 20
+
+type LineErrorDetails struct {
+	Line    int    `json:"line"`
+	Error   string `json:"error"`
+	Details any    `json:"details,omitempty"`
+}
+
+type jsonLinesConfig struct {
+	maxLineSize int
+}
+
+type JSONLinesOption func(*jsonLinesConfig)
+
+func WithMaxLineSize(size int) JSONLinesOption {
+	return func(cfg *jsonLinesConfig) {
+		cfg.maxLineSize = size
+	}
+}
+
+// ReadJSONLines decodes a JSON Lines (NDJSON) body one line at a time, skipping
+// blank lines. Invalid lines yield an error holding the line number, whereas
+// reading errors end the iteration
+func ReadJSONLines[T any](r io.Reader, opts ...JSONLinesOption) iter.Seq2[T, error] {
+	cfg := &jsonLinesConfig{maxLineSize: DefaultMaxLineSize}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(yield func(T, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, min(64*1024, cfg.maxLineSize)), cfg.maxLineSize)
+
+		line := 0
+
+		for scanner.Scan() {
+			line++
+
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+
+			var v T
+
+			if err := json.Unmarshal(data, &v); err != nil {
+				if !yield(v, lineError(line, err)) {
+					return
+				}
+				continue
+			}
+
+			if err := verifyValidator(v); err != nil {
+				if !yield(v, lineError(line, err)) {
+					return
+				}
+				continue
+			}
+
+			if !yield(v, nil) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			var v T
+
+			if errors.Is(err, bufio.ErrTooLong) {
+				yield(v, lineError(line+1, NewError(http.StatusRequestEntityTooLarge, "JSON line too long")))
+				return
+			}
+
+			yield(v, readError(err, "unable to read body"))
+		}
+	}
+}
+
+func lineError(line int, err error) *Error {
+	return itemError("invalid JSON line", err, func(errMessage string, errDetails any) any {
+		return LineErrorDetails{Line: line, Error: errMessage, Details: errDetails}
+	})
+}
+
+// JSONLinesWriter streams values as JSON Lines, flushing each one so that
+// clients receive them as soon as they are produced
+type JSONLinesWriter struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	rc      *http.ResponseController
+	encoder *json.Encoder
+}
+
+// NewJSONLinesWriter sends the status and the application/x-ndjson content type
+func NewJSONLinesWriter(w http.ResponseWriter, r *http.Request, code int) *JSONLinesWriter {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(code)
+
+	return &JSONLinesWriter{
+		w:       w,
+		r:       r,
+		rc:      http.NewResponseController(w),
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Write encodes v on its own line. It fails with the context error once the
+// client is gone, so that producers stop early
+func (jw *JSONLinesWriter) Write(v any) error {
+	if err := jw.r.Context().Err(); err != nil {
+		return err
+	}
+
+	if err := jw.encoder.Encode(v); err != nil {
+		return err
+	}
+
+	if err := jw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	return nil
+}
+
+// WriteJSONLines streams items as JSON Lines until the sequence ends or the
+// client disconnects
+func WriteJSONLines[T any](w http.ResponseWriter, r *http.Request, code int, items iter.Seq[T]) error {
+	jw := NewJSONLinesWriter(w, r, code)
+
+	for item := range items {
+		if err := jw.Write(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
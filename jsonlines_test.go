@@ -0,0 +1,153 @@
+package httpbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lineItem struct {
+	Name string `json:"name"`
+}
+
+func (i lineItem) Validate() error {
+	if i.Name == "" {
+		return NewError(http.StatusUnprocessableEntity, "name is required")
+	}
+	return nil
+}
+
+func TestReadJSONLines(t *testing.T) {
+	body := "{\"name\":\"a\"}\n\n  \r\n{\"name\":\"b\"}\r\n{\"name\":\"c\"}"
+
+	var names []string
+	for item, err := range ReadJSONLines[lineItem](strings.NewReader(body)) {
+		require.NoError(t, err)
+		names = append(names, item.Name)
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
+
+func TestReadJSONLines_Errors(t *testing.T) {
+	body := "{\"name\":\"a\"}\n{\"name\":\n{\"name\":\"\"}\n{\"name\":\"d\"}\n"
+
+	var names []string
+	var errs []*Error
+
+	for item, err := range ReadJSONLines[lineItem](strings.NewReader(body)) {
+		if err != nil {
+			var httpErr *Error
+			require.True(t, errors.As(err, &httpErr))
+			errs = append(errs, httpErr)
+			continue
+		}
+		names = append(names, item.Name)
+	}
+
+	assert.Equal(t, []string{"a", "d"}, names)
+	require.Len(t, errs, 2)
+
+	assert.Equal(t, http.StatusBadRequest, errs[0].Code)
+	assert.Equal(t, "invalid JSON line", errs[0].Message)
+	assert.Equal(t, 2, errs[0].Details.(LineErrorDetails).Line)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, errs[1].Code)
+	assert.Equal(t, LineErrorDetails{Line: 3, Error: "name is required"}, errs[1].Details)
+}
+
+type loggedLineItem struct {
+	Name string `json:"name"`
+}
+
+func (i loggedLineItem) Validate() error {
+	return NewError(http.StatusInternalServerError, "lookup failed", WithInternalError(errors.New("db down")), WithLog())
+}
+
+func TestReadJSONLines_KeepsErrorFields(t *testing.T) {
+	for _, err := range ReadJSONLines[loggedLineItem](strings.NewReader("{\"name\":\"a\"}\n")) {
+		var httpErr *Error
+		require.True(t, errors.As(err, &httpErr))
+
+		assert.Equal(t, http.StatusInternalServerError, httpErr.Code)
+		assert.True(t, httpErr.Log)
+		assert.EqualError(t, httpErr.Err, "db down")
+		assert.Equal(t, LineErrorDetails{Line: 1, Error: "lookup failed"}, httpErr.Details)
+	}
+}
+
+func TestReadJSONLines_Stop(t *testing.T) {
+	body := "{\"name\":\"a\"}\n{\"name\":\"b\"}\n{\"name\":\"c\"}\n"
+
+	count := 0
+	for range ReadJSONLines[lineItem](strings.NewReader(body)) {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+
+	assert.Equal(t, 2, count)
+}
+
+func TestReadJSONLines_LineTooLong(t *testing.T) {
+	body := "{\"name\":\"a\"}\n{\"name\":\"" + strings.Repeat("b", 100) + "\"}\n"
+
+	var lastErr error
+	count := 0
+	for _, err := range ReadJSONLines[lineItem](strings.NewReader(body), WithMaxLineSize(50)) {
+		count++
+		lastErr = err
+	}
+
+	assert.Equal(t, 2, count)
+
+	var httpErr *Error
+	require.True(t, errors.As(lastErr, &httpErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+	assert.Equal(t, 2, httpErr.Details.(LineErrorDetails).Line)
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/items", nil)
+
+	err := WriteJSONLines(rec, req, http.StatusOK, slices.Values([]lineItem{{"a"}, {"b"}}))
+
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"name\":\"a\"}\n{\"name\":\"b\"}\n", rec.Body.String())
+	assert.True(t, rec.Flushed)
+}
+
+func TestWriteJSONLines_ClientDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/items", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	produced := 0
+	items := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			produced++
+			if i == 2 {
+				cancel()
+			}
+			if !yield(i) {
+				return
+			}
+		}
+	}
+
+	err := WriteJSONLines(rec, req, http.StatusOK, items)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 3, produced)
+	assert.Equal(t, "0\n1\n", rec.Body.String())
+}
@@ -0,0 +1,505 @@
+package httpbox
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmES256 = "ES256"
+)
+
+const minRSAKeyBits = 2048
+
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	Key       any
+}
+
+func HS256Key(id string, secret []byte) JWTKey {
+	return JWTKey{ID: id, Algorithm: JWTAlgorithmHS256, Key: secret}
+}
+
+func RS256Key(id string, key *rsa.PublicKey) JWTKey {
+	return JWTKey{ID: id, Algorithm: JWTAlgorithmRS256, Key: key}
+}
+
+func ES256Key(id string, key *ecdsa.PublicKey) JWTKey {
+	return JWTKey{ID: id, Algorithm: JWTAlgorithmES256, Key: key}
+}
+
+type JWTKeySet struct {
+	keys []JWTKey
+}
+
+func NewJWTKeySet(keys ...JWTKey) *JWTKeySet {
+	return &JWTKeySet{keys: keys}
+}
+
+func (ks *JWTKeySet) Keys() []JWTKey {
+	return slices.Clone(ks.keys)
+}
+
+// candidates returns the keys able to verify a token. The algorithm must match
+// the key, which prevents tokens from choosing how a key is interpreted
+func (ks *JWTKeySet) candidates(kid, alg string) []JWTKey {
+	var keys []JWTKey
+
+	for _, key := range ks.keys {
+		if key.Algorithm != alg || (kid != "" && key.ID != "" && key.ID != kid) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JSON Web Key Set. Keys meant for encryption or using
+// unsupported algorithms are skipped
+func ParseJWKS(data []byte) (*JWTKeySet, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	ks := &JWTKeySet{}
+
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.toJWTKey()
+		if errors.Is(err, errUnsupportedJWK) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %d: %w", i, err)
+		}
+
+		ks.keys = append(ks.keys, key)
+	}
+
+	return ks, nil
+}
+
+var errUnsupportedJWK = errors.New("unsupported key")
+
+func (k jwk) toJWTKey() (JWTKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch {
+	case k.Kty == "RSA" && (k.Alg == "" || k.Alg == JWTAlgorithmRS256):
+		n, err := decode(k.N)
+		if err != nil {
+			return JWTKey{}, err
+		}
+
+		e, err := decode(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return JWTKey{}, errors.New("invalid RSA exponent")
+		}
+
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+
+		if key.N.BitLen() < minRSAKeyBits {
+			return JWTKey{}, fmt.Errorf("RSA keys must have at least %d bits", minRSAKeyBits)
+		}
+
+		return RS256Key(k.Kid, key), nil
+
+	case k.Kty == "EC" && k.Crv == "P-256" && (k.Alg == "" || k.Alg == JWTAlgorithmES256):
+		x, err := decode(k.X)
+		if err != nil {
+			return JWTKey{}, err
+		}
+
+		y, err := decode(k.Y)
+		if err != nil {
+			return JWTKey{}, err
+		}
+
+		key, err := newP256PublicKey(x, y)
+		if err != nil {
+			return JWTKey{}, err
+		}
+
+		return ES256Key(k.Kid, key), nil
+
+	case k.Kty == "oct" && (k.Alg == "" || k.Alg == JWTAlgorithmHS256):
+		secret, err := decode(k.K)
+		if err != nil {
+			return JWTKey{}, err
+		}
+
+		return HS256Key(k.Kid, secret), nil
+	}
+
+	return JWTKey{}, errUnsupportedJWK
+}
+
+func newP256PublicKey(x, y []byte) (*ecdsa.PublicKey, error) {
+	if len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("invalid P-256 coordinates")
+	}
+
+	// crypto/ecdh validates that the point is on the curve
+	point := append([]byte{4}, append(x, y...)...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, err
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+func LoadJWKSFile(path string) (*JWTKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
+
+// LoadJWKSFromHandler requests the key set from an in-process handler, such as
+// the one of an identity provider embedded in the same binary
+func LoadJWKSFromHandler(h http.Handler, target string) (*JWTKeySet, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := newResponseBuffer()
+	h.ServeHTTP(rec, req)
+
+	if rec.statusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS handler returned status %d", rec.statusCode)
+	}
+
+	return ParseJWKS(rec.body.Bytes())
+}
+
+type NumericDate struct {
+	time.Time
+}
+
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{t.Truncate(time.Second)}
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, d.Unix(), 10), nil
+}
+
+func (d *NumericDate) UnmarshalJSON(data []byte) error {
+	seconds, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid numeric date: %w", err)
+	}
+
+	// Splitting the fraction keeps dates past 2262 from overflowing nanoseconds
+	sec, frac := math.Modf(seconds)
+	if math.IsNaN(sec) || math.Abs(sec) >= math.MaxInt64 {
+		return fmt.Errorf("invalid numeric date: %s is out of range", data)
+	}
+
+	d.Time = time.Unix(int64(sec), int64(frac*float64(time.Second)))
+	return nil
+}
+
+// Audience accepts both the string and the array forms of the aud claim
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var single string
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		*a = Audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+
+	return nil
+}
+
+type RegisteredClaims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	JWTID     string       `json:"jti,omitempty"`
+}
+
+func (c RegisteredClaims) Identity() string {
+	return c.Subject
+}
+
+type jwtConfig struct {
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	requireExp bool
+	realm      string
+	now        func() time.Time
+}
+
+type JWTOption func(*jwtConfig)
+
+func WithJWTIssuer(issuer string) JWTOption {
+	return func(cfg *jwtConfig) {
+		cfg.issuer = issuer
+	}
+}
+
+func WithJWTAudience(audience string) JWTOption {
+	return func(cfg *jwtConfig) {
+		cfg.audience = audience
+	}
+}
+
+// WithJWTLeeway tolerates clock skew between the issuer and this server when
+// validating exp, nbf and iat
+func WithJWTLeeway(leeway time.Duration) JWTOption {
+	return func(cfg *jwtConfig) {
+		cfg.leeway = leeway
+	}
+}
+
+// WithoutJWTExpiration accepts tokens without an exp claim
+func WithoutJWTExpiration() JWTOption {
+	return func(cfg *jwtConfig) {
+		cfg.requireExp = false
+	}
+}
+
+func WithJWTRealm(realm string) JWTOption {
+	return func(cfg *jwtConfig) {
+		cfg.realm = realm
+	}
+}
+
+func newJWTConfig(opts []JWTOption) *jwtConfig {
+	cfg := &jwtConfig{
+		requireExp: true,
+		realm:      "api",
+		now:        time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// VerifyJWT checks the signature and registered claims of a compact JWT and
+// decodes its payload into C
+func VerifyJWT[C any](token string, keys *JWTKeySet, opts ...JWTOption) (C, error) {
+	claims, _, err := verifyJWT[C](token, keys, newJWTConfig(opts))
+	return claims, err
+}
+
+func verifyJWT[C any](token string, keys *JWTKeySet, cfg *jwtConfig) (C, RegisteredClaims, error) {
+	var claims C
+	var registered RegisteredClaims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, registered, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return claims, registered, fmt.Errorf("invalid header: %w", err)
+	}
+
+	// Critical extensions are not supported, so such tokens must be rejected
+	if len(header.Crit) > 0 {
+		return claims, registered, errors.New("unsupported critical header parameters")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, registered, errors.New("invalid signature encoding")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+
+	verified := false
+	for _, key := range keys.candidates(header.Kid, header.Alg) {
+		if verifyJWTSignature(key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return claims, registered, errors.New("invalid signature")
+	}
+
+	if err := decodeJWTSegment(parts[1], &registered); err != nil {
+		return claims, registered, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	if err := cfg.validate(registered); err != nil {
+		return claims, registered, err
+	}
+
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return claims, registered, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	return claims, registered, nil
+}
+
+func decodeJWTSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func verifyJWTSignature(key JWTKey, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+
+	switch k := key.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+
+	case *ecdsa.PublicKey:
+		// JWS uses the fixed size r || s encoding instead of ASN.1
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+	}
+
+	return false
+}
+
+func (cfg *jwtConfig) validate(claims RegisteredClaims) error {
+	now := cfg.now()
+
+	if claims.ExpiresAt == nil && cfg.requireExp {
+		return errors.New("token has no expiration")
+	}
+
+	if claims.ExpiresAt != nil && !now.Before(claims.ExpiresAt.Add(cfg.leeway)) {
+		return errors.New("token is expired")
+	}
+
+	if claims.NotBefore != nil && now.Add(cfg.leeway).Before(claims.NotBefore.Time) {
+		return errors.New("token is not valid yet")
+	}
+
+	if claims.IssuedAt != nil && now.Add(cfg.leeway).Before(claims.IssuedAt.Time) {
+		return errors.New("token was issued in the future")
+	}
+
+	if cfg.issuer != "" && claims.Issuer != cfg.issuer {
+		return errors.New("token has an unexpected issuer")
+	}
+
+	if cfg.audience != "" && !slices.Contains(claims.Audience, cfg.audience) {
+		return errors.New("token has an unexpected audience")
+	}
+
+	return nil
+}
+
+// JWTMiddleware authenticates requests with a bearer JWT, storing the decoded
+// claims in the request context. C is used as the Principal when it implements
+// the interface, otherwise the subject claim is
+func JWTMiddleware[C any](keys *JWTKeySet, opts ...JWTOption) Middleware {
+	cfg := newJWTConfig(opts)
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			token, ok := bearerToken(r)
+			if !ok {
+				return newUnauthorizedError(w, bearerChallenge(cfg.realm, "", ""), "authentication required")
+			}
+
+			claims, registered, err := verifyJWT[C](token, keys, cfg)
+			if err != nil {
+				challenge := bearerChallenge(cfg.realm, "invalid_token", err.Error())
+				return newUnauthorizedError(w, challenge, "invalid token")
+			}
+
+			principal, ok := any(claims).(Principal)
+			if !ok {
+				principal = SubjectPrincipal(registered.Subject)
+			}
+
+			return h(w, withPrincipal(r, principal, claims))
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package httpbox
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClaims struct {
+	RegisteredClaims
+	Name string `json:"name"`
+}
+
+func encodeJWTSegment(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signJWT(t *testing.T, alg, kid string, key any, claims any) string {
+	t.Helper()
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+
+	signed := encodeJWTSegment(t, header) + "." + encodeJWTSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() testClaims {
+	return testClaims{
+		RegisteredClaims: RegisteredClaims{
+			Issuer:    "https://issuer.example.com",
+			Subject:   "user-1",
+			Audience:  Audience{"api"},
+			ExpiresAt: NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  NewNumericDate(time.Now()),
+		},
+		Name: "Alice",
+	}
+}
+
+func TestVerifyJWT_Algorithms(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	keys := NewJWTKeySet(
+		HS256Key("hs", secret),
+		RS256Key("rs", &rsaKey.PublicKey),
+		ES256Key("es", &ecKey.PublicKey),
+	)
+
+	tests := []struct {
+		name string
+		alg  string
+		kid  string
+		key  any
+	}{
+		{"HS256", JWTAlgorithmHS256, "hs", secret},
+		{"RS256", JWTAlgorithmRS256, "rs", rsaKey},
+		{"ES256", JWTAlgorithmES256, "es", ecKey},
+		{"without kid", JWTAlgorithmES256, "", ecKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signJWT(t, tt.alg, tt.kid, tt.key, validClaims())
+
+			claims, err := VerifyJWT[testClaims](token, keys)
+
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims.Subject)
+			assert.Equal(t, "Alice", claims.Name)
+		})
+	}
+}
+
+func TestVerifyJWT_Invalid(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keys := NewJWTKeySet(HS256Key("hs", secret))
+
+	expired := validClaims()
+	expired.ExpiresAt = NewNumericDate(time.Now().Add(-time.Minute))
+
+	notYetValid := validClaims()
+	notYetValid.NotBefore = NewNumericDate(time.Now().Add(time.Hour))
+
+	farFuture := validClaims()
+	farFuture.NotBefore = NewNumericDate(time.Unix(1e11, 0))
+
+	withoutExp := validClaims()
+	withoutExp.ExpiresAt = nil
+
+	valid := signJWT(t, JWTAlgorithmHS256, "hs", secret, validClaims())
+
+	tests := []struct {
+		name  string
+		token string
+		opts  []JWTOption
+		err   string
+	}{
+		{"malformed", "not.a-token", nil, "malformed token"},
+		{"wrong secret", signJWT(t, JWTAlgorithmHS256, "hs", []byte("other"), validClaims()), nil, "invalid signature"},
+		{"unknown kid", signJWT(t, JWTAlgorithmHS256, "other", secret, validClaims()), nil, "invalid signature"},
+		{"none algorithm", signJWT(t, "none", "hs", secret, validClaims()), nil, "invalid signature"},
+		{"tampered payload", valid[:len(valid)-2] + "xx", nil, "invalid signature"},
+		{"expired", signJWT(t, JWTAlgorithmHS256, "hs", secret, expired), nil, "token is expired"},
+		{"not valid yet", signJWT(t, JWTAlgorithmHS256, "hs", secret, notYetValid), nil, "token is not valid yet"},
+		{"not valid until far future", signJWT(t, JWTAlgorithmHS256, "hs", secret, farFuture), nil, "token is not valid yet"},
+		{"missing expiration", signJWT(t, JWTAlgorithmHS256, "hs", secret, withoutExp), nil, "token has no expiration"},
+		{"wrong issuer", valid, []JWTOption{WithJWTIssuer("https://other.example.com")}, "unexpected issuer"},
+		{"wrong audience", valid, []JWTOption{WithJWTAudience("billing")}, "unexpected audience"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyJWT[testClaims](tt.token, keys, tt.opts...)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestVerifyJWT_Options(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keys := NewJWTKeySet(HS256Key("", secret))
+
+	claims := validClaims()
+	claims.ExpiresAt = NewNumericDate(time.Now().Add(-30 * time.Second))
+	token := signJWT(t, JWTAlgorithmHS256, "", secret, claims)
+
+	_, err := VerifyJWT[testClaims](token, keys,
+		WithJWTLeeway(time.Minute),
+		WithJWTIssuer("https://issuer.example.com"),
+		WithJWTAudience("api"),
+	)
+	assert.NoError(t, err)
+
+	claims.ExpiresAt = nil
+	token = signJWT(t, JWTAlgorithmHS256, "", secret, claims)
+
+	_, err = VerifyJWT[testClaims](token, keys, WithoutJWTExpiration())
+	assert.NoError(t, err)
+}
+
+func TestAudience_UnmarshalJSON(t *testing.T) {
+	var single, list RegisteredClaims
+
+	require.NoError(t, json.Unmarshal([]byte(`{"aud":"api"}`), &single))
+	require.NoError(t, json.Unmarshal([]byte(`{"aud":["api","web"]}`), &list))
+
+	assert.Equal(t, Audience{"api"}, single.Audience)
+	assert.Equal(t, Audience{"api", "web"}, list.Audience)
+}
+
+func TestNumericDate_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected time.Time
+	}{
+		{"1700000000", time.Unix(1700000000, 0)},
+		{"1700000000.25", time.Unix(1700000000, 250000000)},
+		{"100000000000", time.Unix(1e11, 0)},
+		{"-1.5", time.Unix(-1, -500000000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var d NumericDate
+
+			require.NoError(t, json.Unmarshal([]byte(tt.data), &d))
+			assert.True(t, tt.expected.Equal(d.Time), d.Time)
+		})
+	}
+
+	var d NumericDate
+	assert.Error(t, json.Unmarshal([]byte("1e300"), &d))
+}
+
+func testJWKS(t *testing.T, rsaKey *rsa.PublicKey, ecKey *ecdsa.PublicKey, secret []byte) []byte {
+	t.Helper()
+
+	encode := base64.RawURLEncoding.EncodeToString
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	ecKey.X.FillBytes(x)
+	ecKey.Y.FillBytes(y)
+
+	jwks := map[string]any{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rs", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "es", "crv": "P-256", "x": encode(x), "y": encode(y)},
+			{"kty": "oct", "kid": "hs", "k": encode(secret)},
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": encode(rsaKey.N.Bytes()), "e": "AQAB"},
+			{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": encode(x)},
+		},
+	}
+
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	data := testJWKS(t, &rsaKey.PublicKey, &ecKey.PublicKey, secret)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	fromFile, err := LoadJWKSFile(path)
+	require.NoError(t, err)
+
+	fromHandler, err := LoadJWKSFromHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}), "/.well-known/jwks.json")
+	require.NoError(t, err)
+
+	for name, keys := range map[string]*JWTKeySet{"file": fromFile, "handler": fromHandler} {
+		t.Run(name, func(t *testing.T) {
+			require.Len(t, keys.Keys(), 3)
+
+			for kid, key := range map[string]any{"rs": rsaKey, "es": ecKey, "hs": secret} {
+				alg := map[string]string{"rs": JWTAlgorithmRS256, "es": JWTAlgorithmES256, "hs": JWTAlgorithmHS256}[kid]
+				token := signJWT(t, alg, kid, key, validClaims())
+
+				_, err := VerifyJWT[testClaims](token, keys)
+				assert.NoError(t, err, kid)
+			}
+		})
+	}
+}
+
+func TestParseJWKS_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not JSON", `keys`},
+		{"small RSA key", `{"keys":[{"kty":"RSA","n":"AQAB","e":"AQAB"}]}`},
+		{"point not on curve", fmt.Sprintf(`{"keys":[{"kty":"EC","crv":"P-256","x":%q,"y":%q}]}`,
+			base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+			base64.RawURLEncoding.EncodeToString(make([]byte, 32)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJWKS([]byte(tt.data))
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadJWKSFromHandler_Status(t *testing.T) {
+	_, err := LoadJWKSFromHandler(http.NotFoundHandler(), "/jwks.json")
+
+	assert.Error(t, err)
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keys := NewJWTKeySet(HS256Key("hs", secret))
+
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		claims, ok := ClaimsFromContext[testClaims](r.Context())
+		require.True(t, ok)
+
+		principal, ok := PrincipalFromContext(r.Context())
+		require.True(t, ok)
+
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte(claims.Name+":"+principal.Identity()))
+	}).WithMiddlewares(JWTMiddleware[testClaims](keys, WithJWTAudience("api")))
+
+	valid := httptest.NewRequest(http.MethodGet, "/test", nil)
+	valid.Header.Set("Authorization", "Bearer "+signJWT(t, JWTAlgorithmHS256, "hs", secret, validClaims()))
+	validRec := httptest.NewRecorder()
+	h.ServeHTTP(validRec, valid)
+
+	assert.Equal(t, http.StatusOK, validRec.Code)
+	assert.Equal(t, "Alice:user-1", validRec.Body.String())
+
+	expired := validClaims()
+	expired.ExpiresAt = NewNumericDate(time.Now().Add(-time.Hour))
+	invalid := httptest.NewRequest(http.MethodGet, "/test", nil)
+	invalid.Header.Set("Authorization", "Bearer "+signJWT(t, JWTAlgorithmHS256, "hs", secret, expired))
+	invalidRec := httptest.NewRecorder()
+	h.ServeHTTP(invalidRec, invalid)
+
+	assert.Equal(t, http.StatusUnauthorized, invalidRec.Code)
+	assert.Equal(t, `Bearer realm="api", error="invalid_token", error_description="token is expired"`, invalidRec.Header().Get("WWW-Authenticate"))
+	assert.JSONEq(t, `{"code":401,"message":"invalid token"}`, invalidRec.Body.String())
+}
+
+func TestJWTMiddleware_NonPrincipalClaims(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keys := NewJWTKeySet(HS256Key("hs", secret))
+
+	h := principalEchoHandler().WithMiddlewares(JWTMiddleware[map[string]any](keys))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, JWTAlgorithmHS256, "hs", secret, validClaims()))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "user-1", rec.Body.String())
+}
@@ -0,0 +1,19 @@
+package httpbox
+
+import "context"
+
+// logAttrs returns the request scoped attributes that should be attached to
+// every log line emitted by httpbox while serving a request
+func logAttrs(ctx context.Context) []any {
+	var attrs []any
+
+	if id := RequestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, "request_id", id)
+	}
+
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		attrs = append(attrs, "trace_id", sc.TraceID.String(), "span_id", sc.SpanID.String())
+	}
+
+	return attrs
+}
@@ -0,0 +1,360 @@
+package httpbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	DefaultSizeBuckets    = []float64{100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000}
+)
+
+// Requests not matched by any route are grouped under this label to keep the
+// number of series bounded regardless of the URLs clients send
+const UnmatchedRoute = "unmatched"
+
+type metricType string
+
+const (
+	metricCounter   metricType = "counter"
+	metricGauge     metricType = "gauge"
+	metricHistogram metricType = "histogram"
+)
+
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metricVec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*metricVec)}
+}
+
+type metricVec struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// register returns the already registered metric when the definitions match,
+// so middlewares can be instantiated several times over the same Registry
+func (reg *Registry) register(name, help string, typ metricType, buckets []float64, labels []string) *metricVec {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if m, ok := reg.metrics[name]; ok {
+		if m.typ != typ || !slices.Equal(m.labels, labels) || !slices.Equal(m.buckets, buckets) {
+			panic(fmt.Sprintf("httpbox: metric %q already registered with a different definition", name))
+		}
+		return m
+	}
+
+	m := &metricVec{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  slices.Clone(labels),
+		buckets: slices.Clone(buckets),
+		series:  make(map[string]*series),
+	}
+	reg.metrics[name] = m
+
+	return m
+}
+
+func (m *metricVec) with(labelValues []string, update func(s *series)) {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("httpbox: metric %q expects %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		if m.typ == metricHistogram {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+
+	update(s)
+}
+
+type CounterVec struct {
+	vec *metricVec
+}
+
+func (reg *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec: reg.register(name, help, metricCounter, nil, labels)}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("httpbox: counters cannot decrease")
+	}
+
+	c.vec.with(labelValues, func(s *series) { s.value += v })
+}
+
+type GaugeVec struct {
+	vec *metricVec
+}
+
+func (reg *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{vec: reg.register(name, help, metricGauge, nil, labels)}
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.vec.with(labelValues, func(s *series) { s.value = v })
+}
+
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.vec.with(labelValues, func(s *series) { s.value += v })
+}
+
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+type HistogramVec struct {
+	vec *metricVec
+}
+
+func (reg *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+
+	// The +Inf bucket is always rendered, so it is not stored with the others
+	buckets = slices.DeleteFunc(buckets, func(b float64) bool { return math.IsInf(b, 1) })
+
+	return &HistogramVec{vec: reg.register(name, help, metricHistogram, slices.Compact(buckets), labels)}
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.vec.with(labelValues, func(s *series) {
+		for i, upper := range h.vec.buckets {
+			if v <= upper {
+				s.counts[i]++
+			}
+		}
+		s.sum += v
+		s.count++
+	})
+}
+
+// WriteTo renders every metric using the Prometheus text exposition format
+func (reg *Registry) WriteTo(w io.Writer) (int64, error) {
+	reg.mu.Lock()
+	metrics := make([]*metricVec, 0, len(reg.metrics))
+	for _, m := range reg.metrics {
+		metrics = append(metrics, m)
+	}
+	reg.mu.Unlock()
+
+	slices.SortFunc(metrics, func(a, b *metricVec) int { return strings.Compare(a.name, b.name) })
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		m.writeTo(&buf)
+	}
+
+	return buf.WriteTo(w)
+}
+
+func (m *metricVec) writeTo(buf *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(buf, "# HELP %s %s\n", m.name, metricHelpEscaper.Replace(m.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", m.name, m.typ)
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+
+		if m.typ != metricHistogram {
+			fmt.Fprintf(buf, "%s%s %s\n", m.name, m.formatLabels(s.labelValues, ""), formatMetricValue(s.value))
+			continue
+		}
+
+		for i, upper := range m.buckets {
+			le := formatMetricValue(upper)
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", m.name, m.formatLabels(s.labelValues, le), s.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", m.name, m.formatLabels(s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", m.name, m.formatLabels(s.labelValues, ""), formatMetricValue(s.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", m.name, m.formatLabels(s.labelValues, ""), s.count)
+	}
+}
+
+func (m *metricVec) formatLabels(values []string, le string) string {
+	if len(values) == 0 && le == "" {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(values)+1)
+	for i, value := range values {
+		pairs = append(pairs, m.labels[i]+`="`+metricLabelEscaper.Replace(value)+`"`)
+	}
+
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	metricHelpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	metricLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func (reg *Registry) Handler() Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		_, err := reg.WriteTo(w)
+		return err
+	}
+}
+
+type metricsConfig struct {
+	latencyBuckets []float64
+	sizeBuckets    []float64
+	unmatchedRoute string
+}
+
+type MetricsOption func(*metricsConfig)
+
+func WithMetricsLatencyBuckets(buckets ...float64) MetricsOption {
+	return func(cfg *metricsConfig) {
+		cfg.latencyBuckets = buckets
+	}
+}
+
+func WithMetricsSizeBuckets(buckets ...float64) MetricsOption {
+	return func(cfg *metricsConfig) {
+		cfg.sizeBuckets = buckets
+	}
+}
+
+func WithMetricsUnmatchedRoute(label string) MetricsOption {
+	return func(cfg *metricsConfig) {
+		cfg.unmatchedRoute = label
+	}
+}
+
+func MetricsMiddleware(reg *Registry, opts ...MetricsOption) Middleware {
+	cfg := &metricsConfig{
+		latencyBuckets: DefaultLatencyBuckets,
+		sizeBuckets:    DefaultSizeBuckets,
+		unmatchedRoute: UnmatchedRoute,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	requests := reg.Counter("http_requests_total",
+		"Total number of HTTP requests.", "method", "route", "status")
+	inFlight := reg.Gauge("http_requests_in_flight",
+		"Number of HTTP requests currently being served.", "method")
+	latency := reg.Histogram("http_request_duration_seconds",
+		"Duration of HTTP requests in seconds.", cfg.latencyBuckets, "method", "route", "status")
+	size := reg.Histogram("http_response_size_bytes",
+		"Size of HTTP response bodies in bytes.", cfg.sizeBuckets, "method", "route", "status")
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			method := metricMethod(r.Method)
+
+			inFlight.Inc(method)
+			defer inFlight.Dec(method)
+
+			r, _ = withRequestState(r)
+			arw := newAccessResponseWriter(w)
+			start := time.Now()
+
+			err := h(arw, r)
+
+			// The pattern is only known once the request went through the mux
+			route := routePattern(r)
+			if route == "" {
+				route = cfg.unmatchedRoute
+			}
+
+			status := strconv.Itoa(responseStatus(arw, r, err))
+
+			requests.Inc(method, route, status)
+			latency.Observe(time.Since(start).Seconds(), method, route, status)
+			size.Observe(float64(arw.bodySize), method, route, status)
+
+			return err
+		}
+	}
+}
+
+// metricMethod maps non standard methods to a single label, since clients can
+// send arbitrary method names
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+
+	return "OTHER"
+}
@@ -0,0 +1,186 @@
+package httpbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderMetrics(t *testing.T, reg *Registry) string {
+	t.Helper()
+
+	var sb strings.Builder
+	_, err := reg.WriteTo(&sb)
+	require.NoError(t, err)
+
+	return sb.String()
+}
+
+func TestRegistry_Counter(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.Counter("jobs_total", "Total jobs.", "queue")
+
+	c.Inc("default")
+	c.Add(2, "default")
+	c.Inc("priority")
+
+	expected := "# HELP jobs_total Total jobs.\n" +
+		"# TYPE jobs_total counter\n" +
+		"jobs_total{queue=\"default\"} 3\n" +
+		"jobs_total{queue=\"priority\"} 1\n"
+
+	assert.Equal(t, expected, renderMetrics(t, reg))
+}
+
+func TestRegistry_Gauge(t *testing.T) {
+	reg := NewRegistry()
+	g := reg.Gauge("temperature", "Current temperature.")
+
+	g.Set(10)
+	g.Inc()
+	g.Dec()
+	g.Add(-2.5)
+
+	assert.Contains(t, renderMetrics(t, reg), "temperature 7.5\n")
+}
+
+func TestRegistry_Histogram(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "op")
+
+	h.Observe(0.05, "read")
+	h.Observe(0.5, "read")
+	h.Observe(5, "read")
+
+	expected := "# HELP latency_seconds Latency.\n" +
+		"# TYPE latency_seconds histogram\n" +
+		"latency_seconds_bucket{op=\"read\",le=\"0.1\"} 1\n" +
+		"latency_seconds_bucket{op=\"read\",le=\"1\"} 2\n" +
+		"latency_seconds_bucket{op=\"read\",le=\"+Inf\"} 3\n" +
+		"latency_seconds_sum{op=\"read\"} 5.55\n" +
+		"latency_seconds_count{op=\"read\"} 3\n"
+
+	assert.Equal(t, expected, renderMetrics(t, reg))
+}
+
+func TestRegistry_Escaping(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.Counter("escaped_total", "Line one\nback\\slash", "value")
+
+	c.Inc("quote\" newline\n backslash\\")
+
+	output := renderMetrics(t, reg)
+
+	assert.Contains(t, output, `# HELP escaped_total Line one\nback\\slash`)
+	assert.Contains(t, output, `escaped_total{value="quote\" newline\n backslash\\"} 1`)
+}
+
+func TestRegistry_DuplicateRegistration(t *testing.T) {
+	reg := NewRegistry()
+
+	first := reg.Counter("dup_total", "Help.", "a")
+	second := reg.Counter("dup_total", "Help.", "a")
+	first.Inc("x")
+	second.Inc("x")
+
+	assert.Contains(t, renderMetrics(t, reg), `dup_total{a="x"} 2`)
+	assert.Panics(t, func() { reg.Gauge("dup_total", "Help.", "a") })
+	assert.Panics(t, func() { reg.Counter("dup_total", "Help.", "b") })
+}
+
+func TestRegistry_WrongLabelCount(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.Counter("labels_total", "Help.", "a", "b")
+
+	assert.Panics(t, func() { c.Inc("only-one") })
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("up", "Help.").Inc()
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "up 1\n")
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	reg := NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte("hello"))
+	}))
+	mux.Handle("DELETE /users/{id}", Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return NewError(http.StatusForbidden, "forbidden")
+	}))
+
+	h := AdaptHandler(mux).WithMiddlewares(MetricsMiddleware(reg, WithMetricsLatencyBuckets(1)))
+
+	requests := []struct {
+		method string
+		target string
+	}{
+		{http.MethodGet, "/users/1"},
+		{http.MethodGet, "/users/2"},
+		{http.MethodDelete, "/users/1"},
+		{http.MethodGet, "/unknown/path"},
+		{"BREW", "/users/1"},
+	}
+
+	for _, req := range requests {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.target, nil))
+	}
+
+	output := renderMetrics(t, reg)
+
+	assert.Contains(t, output, `http_requests_total{method="GET",route="GET /users/{id}",status="200"} 2`)
+	assert.Contains(t, output, `http_requests_total{method="DELETE",route="DELETE /users/{id}",status="403"} 1`)
+	assert.Contains(t, output, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, output, `http_requests_total{method="OTHER",route="unmatched",status="405"} 1`)
+	assert.Contains(t, output, `http_requests_in_flight{method="GET"} 0`)
+	assert.Contains(t, output, `http_request_duration_seconds_bucket{method="GET",route="GET /users/{id}",status="200",le="1"} 2`)
+	assert.Contains(t, output, `http_response_size_bytes_sum{method="GET",route="GET /users/{id}",status="200"} 10`)
+	assert.NotContains(t, output, "/unknown/path")
+}
+
+func TestMetricsMiddleware_ClientClosedRequest(t *testing.T) {
+	reg := NewRegistry()
+
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return r.Context().Err()
+	}).WithMiddlewares(MetricsMiddleware(reg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(ctx))
+
+	assert.Contains(t, renderMetrics(t, reg), `http_requests_total{method="GET",route="unmatched",status="499"} 1`)
+}
+
+func TestMetricsMiddleware_ClientClosedRequestThroughRouter(t *testing.T) {
+	reg := NewRegistry()
+
+	rt := NewRouter()
+	rt.Handle("GET /test", func(w http.ResponseWriter, r *http.Request) error {
+		return r.Context().Err()
+	})
+
+	h := AdaptHandler(rt).WithMiddlewares(MetricsMiddleware(reg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(ctx))
+
+	assert.Contains(t, renderMetrics(t, reg), `http_requests_total{method="GET",route="GET /test",status="499"} 1`)
+}
@@ -1,6 +1,7 @@
 package httpbox
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 )
@@ -16,8 +17,9 @@ func applyMiddlewares(h Handler, middlewares ...Middleware) Handler {
 
 type accessResponseWriter struct {
 	http.ResponseWriter
-	statusCode int
-	bodySize   int
+	statusCode  int
+	bodySize    int
+	wroteHeader bool
 }
 
 func newAccessResponseWriter(w http.ResponseWriter) *accessResponseWriter {
@@ -25,19 +27,54 @@ func newAccessResponseWriter(w http.ResponseWriter) *accessResponseWriter {
 }
 
 func (arw *accessResponseWriter) WriteHeader(statusCode int) {
-	arw.statusCode = statusCode
+	// Informational responses are followed by the final one
+	if !arw.wroteHeader && statusCode >= 200 {
+		arw.statusCode = statusCode
+		arw.wroteHeader = true
+	}
 	arw.ResponseWriter.WriteHeader(statusCode)
 }
 
+func (arw *accessResponseWriter) Unwrap() http.ResponseWriter {
+	return arw.ResponseWriter
+}
+
 func (arw *accessResponseWriter) Write(b []byte) (int, error) {
+	arw.wroteHeader = true
 	size, err := arw.ResponseWriter.Write(b)
 	arw.bodySize += size
 	return size, err
 }
 
+// responseStatus returns the status code the client will receive, taking into
+// account that errors are only written once they reach Handler.ServeHTTP. When
+// wrapping a mux, the error was already handled by the inner Handler, which
+// recorded client disconnects in the request state
+func responseStatus(arw *accessResponseWriter, r *http.Request, err error) int {
+	if IsClientClosedRequest(r, err) {
+		return StatusClientClosedRequest
+	}
+
+	if state := requestStateFrom(r.Context()); state != nil && state.clientClosed {
+		return StatusClientClosedRequest
+	}
+
+	if err == nil || arw.wroteHeader {
+		return arw.statusCode
+	}
+
+	var httpErr *Error
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+
+	return http.StatusInternalServerError
+}
+
 func AccessLogMiddleware() Middleware {
 	return func(h Handler) Handler {
 		return func(w http.ResponseWriter, r *http.Request) error {
+			r, _ = withRequestState(r)
 			arw := newAccessResponseWriter(w)
 
 			err := h(arw, r)
@@ -49,11 +86,11 @@ func AccessLogMiddleware() Middleware {
 			)
 
 			resGroup := slog.Group("res",
-				slog.Int("status", arw.statusCode),
+				slog.Int("status", responseStatus(arw, r, err)),
 				slog.Int("body_size", arw.bodySize),
 			)
 
-			slog.Info("Access", reqGroup, resGroup)
+			slog.Info("Access", append(logAttrs(r.Context()), reqGroup, resGroup)...)
 
 			return err
 		}
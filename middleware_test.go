@@ -1 +1,93 @@
 package httpbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogMiddleware_Status(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  Handler
+		cancel   bool
+		expected string
+	}{
+		{
+			name:     "written response",
+			handler:  textHandler("ok"),
+			expected: "res.status=200 res.body_size=2",
+		},
+		{
+			name: "httpbox error",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return NewError(http.StatusNotFound, "not found")
+			},
+			expected: "res.status=404",
+		},
+		{
+			name: "unknown error",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return context.DeadlineExceeded
+			},
+			expected: "res.status=500",
+		},
+		{
+			name: "client closed request",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				<-r.Context().Done()
+				return r.Context().Err()
+			},
+			cancel:   true,
+			expected: "res.status=499 res.body_size=0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.cancel {
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				req = req.WithContext(ctx)
+			}
+
+			tt.handler.WithMiddlewares(AccessLogMiddleware()).ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Contains(t, logs.String(), tt.expected)
+		})
+	}
+}
+
+func TestAccessLogMiddleware_ClientClosedRequestThroughMux(t *testing.T) {
+	logs := captureLogs(t)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /test", Handler(func(w http.ResponseWriter, r *http.Request) error {
+		<-r.Context().Done()
+		return r.Context().Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	AdaptHandler(mux).WithMiddlewares(AccessLogMiddleware()).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(ctx))
+
+	assert.Contains(t, logs.String(), "res.status=499 res.body_size=0")
+}
+
+func TestApplyMiddlewares_Order(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	textHandler("ok").
+		WithMiddlewares(headerMiddleware("first"), headerMiddleware("second")).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, []string{"first", "second"}, rec.Header().Values("X-Middleware"))
+}
@@ -0,0 +1,381 @@
+package httpbox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	DefaultMultipartMemoryLimit  = 1 << 20
+	DefaultMultipartMaxFileSize  = 10 << 20
+	DefaultMultipartMaxSize      = 32 << 20
+	DefaultMultipartMaxValueSize = 1 << 20
+)
+
+// sniffLen is the number of bytes http.DetectContentType considers
+const sniffLen = 512
+
+// errTempFile marks temporary file failures, which are server errors
+var errTempFile = errors.New("httpbox: temporary file")
+
+type MultipartErrorDetails struct {
+	Field    string `json:"field,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Error    string `json:"error"`
+}
+
+type multipartConfig struct {
+	memoryLimit  int64
+	maxFileSize  int64
+	maxSize      int64
+	maxValueSize int64
+	contentTypes []string
+	tempDir      string
+	discardFiles bool
+}
+
+type MultipartOption func(*multipartConfig)
+
+// WithMultipartMemoryLimit keeps files of at most size bytes in memory. Larger
+// files are written to temporary files
+func WithMultipartMemoryLimit(size int64) MultipartOption {
+	return func(cfg *multipartConfig) {
+		cfg.memoryLimit = size
+	}
+}
+
+// WithMultipartMaxFileSize rejects files larger than size bytes with 413 errors
+func WithMultipartMaxFileSize(size int64) MultipartOption {
+	return func(cfg *multipartConfig) {
+		cfg.maxFileSize = size
+	}
+}
+
+// WithMultipartMaxSize rejects bodies larger than size bytes with 413 errors
+func WithMultipartMaxSize(size int64) MultipartOption {
+	return func(cfg *multipartConfig) {
+		cfg.maxSize = size
+	}
+}
+
+// WithMultipartMaxValueSize rejects text fields larger than size bytes with 413
+// errors
+func WithMultipartMaxValueSize(size int64) MultipartOption {
+	return func(cfg *multipartConfig) {
+		cfg.maxValueSize = size
+	}
+}
+
+// WithMultipartContentTypes only accepts files whose sniffed content type
+// matches one of contentTypes, such as "image/png" or "image/*". Other files
+// are rejected with 415 errors
+func WithMultipartContentTypes(contentTypes ...string) MultipartOption {
+	return func(cfg *multipartConfig) {
+		cfg.contentTypes = nil
+		for _, contentType := range contentTypes {
+			cfg.contentTypes = append(cfg.contentTypes, strings.ToLower(contentType))
+		}
+	}
+}
+
+// WithMultipartTempDir sets the directory of temporary files, os.TempDir by
+// default
+func WithMultipartTempDir(dir string) MultipartOption {
+	return func(cfg *multipartConfig) {
+		cfg.tempDir = dir
+	}
+}
+
+func newMultipartConfig(opts []MultipartOption) *multipartConfig {
+	cfg := &multipartConfig{
+		memoryLimit:  DefaultMultipartMemoryLimit,
+		maxFileSize:  DefaultMultipartMaxFileSize,
+		maxSize:      DefaultMultipartMaxSize,
+		maxValueSize: DefaultMultipartMaxValueSize,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// MultipartForm holds the values and files of a multipart/form-data body
+type MultipartForm struct {
+	Value url.Values
+	File  map[string][]*FormFile
+}
+
+// RemoveAll deletes the temporary files of the form
+func (f *MultipartForm) RemoveAll() error {
+	var errs []error
+
+	for _, files := range f.File {
+		for _, file := range files {
+			errs = append(errs, file.Remove())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// FormFile is an uploaded file, held in memory or in a temporary file
+type FormFile struct {
+	Field    string
+	Filename string
+	// ContentType is sniffed from the content, the type declared by the client
+	// is in Header
+	ContentType string
+	Size        int64
+	Header      textproto.MIMEHeader
+
+	data []byte
+	path string
+}
+
+// Open returns the content of the file
+func (f *FormFile) Open() (io.ReadSeekCloser, error) {
+	if f.path != "" {
+		return os.Open(f.path)
+	}
+
+	return memoryFile{bytes.NewReader(f.data)}, nil
+}
+
+// Remove deletes the temporary file holding the content, if any
+func (f *FormFile) Remove() error {
+	if f.path == "" {
+		return nil
+	}
+
+	path := f.path
+	f.path = ""
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error {
+	return nil
+}
+
+// ReadMultipart streams a multipart/form-data body. Files are kept in memory
+// up to the memory limit and written to temporary files above it, which the
+// caller removes with MultipartForm.RemoveAll. Bodies that are not multipart
+// are rejected with 415 errors, malformed ones with 400 errors
+func ReadMultipart(r *http.Request, opts ...MultipartOption) (*MultipartForm, error) {
+	return readMultipart(r, newMultipartConfig(opts))
+}
+
+func readMultipart(r *http.Request, cfg *multipartConfig) (*MultipartForm, error) {
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return nil, NewError(http.StatusUnsupportedMediaType, "unsupported content type", WithDetails(mediaType))
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, NewError(http.StatusBadRequest, "invalid multipart body", WithDetails(MultipartErrorDetails{Error: "missing boundary"}))
+	}
+
+	var body io.Reader = r.Body
+	if cfg.maxSize > 0 {
+		body = newLimitedReader(r.Body, cfg.maxSize)
+	}
+
+	form := &MultipartForm{Value: make(url.Values), File: make(map[string][]*FormFile)}
+
+	if err := form.read(multipart.NewReader(body, boundary), cfg); err != nil {
+		form.RemoveAll()
+		return nil, err
+	}
+
+	return form, nil
+}
+
+func (f *MultipartForm) read(mr *multipart.Reader, cfg *multipartConfig) error {
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return multipartError("", "", err)
+		}
+
+		field := part.FormName()
+		filename := part.FileName()
+
+		switch {
+		case field == "":
+			_, err = io.Copy(io.Discard, part)
+
+		case filename == "":
+			var value string
+			value, err = readFormValue(part, cfg)
+			f.Value[field] = append(f.Value[field], value)
+
+		case cfg.discardFiles:
+			_, err = io.Copy(io.Discard, part)
+
+		default:
+			var file *FormFile
+			file, err = readFormFile(part, cfg)
+			if file != nil {
+				f.File[field] = append(f.File[field], file)
+			}
+		}
+
+		part.Close()
+
+		if err != nil {
+			return multipartError(field, filename, err)
+		}
+	}
+}
+
+func readFormValue(part *multipart.Part, cfg *multipartConfig) (string, error) {
+	content := io.Reader(part)
+	if cfg.maxValueSize > 0 {
+		content = io.LimitReader(part, cfg.maxValueSize+1)
+	}
+
+	value, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.maxValueSize > 0 && int64(len(value)) > cfg.maxValueSize {
+		return "", NewError(http.StatusRequestEntityTooLarge, "form value too large", WithDetails(MultipartErrorDetails{
+			Field: part.FormName(),
+			Error: fmt.Sprintf("exceeds %d bytes", cfg.maxValueSize),
+		}))
+	}
+
+	return string(value), nil
+}
+
+func readFormFile(part *multipart.Part, cfg *multipartConfig) (*FormFile, error) {
+	file := &FormFile{
+		Field:    part.FormName(),
+		Filename: part.FileName(),
+		Header:   part.Header,
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	file.ContentType = http.DetectContentType(head)
+
+	if len(cfg.contentTypes) > 0 && !matchContentType(file.ContentType, cfg.contentTypes) {
+		return nil, NewError(http.StatusUnsupportedMediaType, "unsupported file type", WithDetails(MultipartErrorDetails{
+			Field:    file.Field,
+			Filename: file.Filename,
+			Error:    file.ContentType + " is not allowed",
+		}))
+	}
+
+	content := io.MultiReader(bytes.NewReader(head), part)
+	if cfg.maxFileSize > 0 {
+		// Reading one byte past the limit tells files of exactly the limit apart
+		content = io.LimitReader(content, cfg.maxFileSize+1)
+	}
+
+	var buf bytes.Buffer
+
+	size, err := io.CopyN(&buf, content, cfg.memoryLimit+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if size <= cfg.memoryLimit {
+		file.data = buf.Bytes()
+	} else {
+		// The file is returned even on failure, so that it gets removed
+		tmp, err := os.CreateTemp(cfg.tempDir, "httpbox-upload-*")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errTempFile, err)
+		}
+
+		file.path = tmp.Name()
+
+		written, err := io.Copy(tempFileWriter{tmp}, io.MultiReader(&buf, content))
+		closeErr := tmp.Close()
+
+		if err != nil {
+			return file, err
+		}
+		if closeErr != nil {
+			return file, fmt.Errorf("%w: %w", errTempFile, closeErr)
+		}
+
+		size = written
+	}
+
+	file.Size = size
+
+	if cfg.maxFileSize > 0 && size > cfg.maxFileSize {
+		return file, NewError(http.StatusRequestEntityTooLarge, "file too large", WithDetails(MultipartErrorDetails{
+			Field:    file.Field,
+			Filename: file.Filename,
+			Error:    fmt.Sprintf("exceeds %d bytes", cfg.maxFileSize),
+		}))
+	}
+
+	return file, nil
+}
+
+// tempFileWriter tells write failures apart from failures to read the part
+type tempFileWriter struct {
+	f *os.File
+}
+
+func (tw tempFileWriter) Write(p []byte) (int, error) {
+	n, err := tw.f.Write(p)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", errTempFile, err)
+	}
+
+	return n, err
+}
+
+// multipartError reports err as a bad request tied to a part. *Error values
+// are returned as is, such as the 413 errors of bodies over the size limit
+func multipartError(field, filename string, err error) error {
+	var httpErr *Error
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &httpErr) || errors.As(err, &maxBytesErr) {
+		return readError(err, "")
+	}
+
+	if errors.Is(err, errTempFile) {
+		return err
+	}
+
+	return NewError(http.StatusBadRequest, "invalid multipart body", WithDetails(MultipartErrorDetails{
+		Field:    field,
+		Filename: filename,
+		Error:    err.Error(),
+	}))
+}
@@ -0,0 +1,252 @@
+package httpbox
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+type testPart struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+func multipartRequest(t *testing.T, parts ...testPart) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range parts {
+		var w io.Writer
+		var err error
+
+		if part.filename == "" {
+			w, err = mw.CreateFormField(part.field)
+		} else {
+			w, err = mw.CreateFormFile(part.field, part.filename)
+		}
+		require.NoError(t, err)
+
+		_, err = w.Write(part.content)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return r
+}
+
+func readFile(t *testing.T, file *FormFile) []byte {
+	t.Helper()
+
+	f, err := file.Open()
+	require.NoError(t, err)
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+
+	return data
+}
+
+func tempFiles(t *testing.T, dir string) []os.DirEntry {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	return entries
+}
+
+func TestReadMultipart(t *testing.T) {
+	dir := t.TempDir()
+	small := append(pngHeader, "small"...)
+	large := append(pngHeader, bytes.Repeat([]byte("x"), 100)...)
+
+	r := multipartRequest(t,
+		testPart{field: "title", content: []byte("Holidays")},
+		testPart{field: "photo", filename: "small.png", content: small},
+		testPart{field: "photo", filename: "large.png", content: large},
+		testPart{field: "notes", filename: "notes.txt", content: []byte("hello")},
+	)
+
+	form, err := ReadMultipart(r, WithMultipartMemoryLimit(50), WithMultipartTempDir(dir))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Holidays"}, form.Value["title"])
+
+	require.Len(t, form.File["photo"], 2)
+
+	smallFile := form.File["photo"][0]
+	assert.Equal(t, "photo", smallFile.Field)
+	assert.Equal(t, "small.png", smallFile.Filename)
+	assert.Equal(t, "image/png", smallFile.ContentType)
+	assert.Equal(t, int64(len(small)), smallFile.Size)
+	assert.Equal(t, small, readFile(t, smallFile))
+
+	largeFile := form.File["photo"][1]
+	assert.Equal(t, int64(len(large)), largeFile.Size)
+	assert.Equal(t, large, readFile(t, largeFile))
+
+	notes := form.File["notes"][0]
+	assert.Equal(t, "text/plain; charset=utf-8", notes.ContentType)
+	assert.Equal(t, "application/octet-stream", notes.Header.Get("Content-Type"))
+
+	// Only the large file exceeds the memory limit
+	assert.Len(t, tempFiles(t, dir), 1)
+
+	require.NoError(t, form.RemoveAll())
+	assert.Empty(t, tempFiles(t, dir))
+}
+
+func TestReadMultipart_Errors(t *testing.T) {
+	png := append(pngHeader, "image"...)
+
+	tests := []struct {
+		name            string
+		parts           []testPart
+		opts            []MultipartOption
+		expectedCode    int
+		expectedMessage string
+		expectedDetails any
+	}{
+		{
+			name:            "file too large",
+			parts:           []testPart{{field: "photo", filename: "photo.png", content: bytes.Repeat([]byte("x"), 101)}},
+			opts:            []MultipartOption{WithMultipartMaxFileSize(100), WithMultipartMemoryLimit(10)},
+			expectedCode:    http.StatusRequestEntityTooLarge,
+			expectedMessage: "file too large",
+			expectedDetails: MultipartErrorDetails{Field: "photo", Filename: "photo.png", Error: "exceeds 100 bytes"},
+		},
+		{
+			name:            "body too large",
+			parts:           []testPart{{field: "title", content: bytes.Repeat([]byte("x"), 1000)}},
+			opts:            []MultipartOption{WithMultipartMaxSize(500)},
+			expectedCode:    http.StatusRequestEntityTooLarge,
+			expectedMessage: "request body too large",
+		},
+		{
+			name:            "value too large",
+			parts:           []testPart{{field: "title", content: bytes.Repeat([]byte("x"), 101)}},
+			opts:            []MultipartOption{WithMultipartMaxValueSize(100)},
+			expectedCode:    http.StatusRequestEntityTooLarge,
+			expectedMessage: "form value too large",
+			expectedDetails: MultipartErrorDetails{Field: "title", Error: "exceeds 100 bytes"},
+		},
+		{
+			name: "unsupported file type",
+			parts: []testPart{
+				{field: "photo", filename: "photo.png", content: png},
+				{field: "photo", filename: "fake.png", content: []byte("<html><body>not an image</body></html>")},
+			},
+			opts:            []MultipartOption{WithMultipartContentTypes("image/*"), WithMultipartMemoryLimit(0)},
+			expectedCode:    http.StatusUnsupportedMediaType,
+			expectedMessage: "unsupported file type",
+			expectedDetails: MultipartErrorDetails{Field: "photo", Filename: "fake.png", Error: "text/html; charset=utf-8 is not allowed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := multipartRequest(t, tt.parts...)
+
+			form, err := ReadMultipart(r, append(tt.opts, WithMultipartTempDir(dir))...)
+
+			assert.Nil(t, form)
+
+			var httpErr *Error
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tt.expectedCode, httpErr.Code)
+			assert.Equal(t, tt.expectedMessage, httpErr.Message)
+			if tt.expectedDetails != nil {
+				assert.Equal(t, tt.expectedDetails, httpErr.Details)
+			}
+
+			assert.Empty(t, tempFiles(t, dir))
+		})
+	}
+}
+
+func TestReadMultipart_FileOfMaxSize(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 100)
+	r := multipartRequest(t, testPart{field: "file", filename: "file.txt", content: content})
+
+	form, err := ReadMultipart(r, WithMultipartMaxFileSize(100), WithMultipartTempDir(t.TempDir()))
+
+	require.NoError(t, err)
+	defer form.RemoveAll()
+	assert.Equal(t, content, readFile(t, form.File["file"][0]))
+}
+
+func TestReadMultipart_InvalidBody(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		body            string
+		expectedCode    int
+		expectedMessage string
+	}{
+		{"not multipart", "application/json", `{}`, http.StatusUnsupportedMediaType, "unsupported content type"},
+		{"missing boundary", "multipart/form-data", "", http.StatusBadRequest, "invalid multipart body"},
+		{"malformed", "multipart/form-data; boundary=xyz", "--xyz\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\nvalue", http.StatusBadRequest, "invalid multipart body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			_, err := ReadMultipart(r)
+
+			var httpErr *Error
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tt.expectedCode, httpErr.Code)
+			assert.Equal(t, tt.expectedMessage, httpErr.Message)
+		})
+	}
+}
+
+func TestReadMultipart_MaxBytesReader(t *testing.T) {
+	r := multipartRequest(t, testPart{field: "title", content: bytes.Repeat([]byte("x"), 1000)})
+	r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 100)
+
+	_, err := ReadMultipart(r)
+
+	var httpErr *Error
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+}
+
+func TestReadMultipart_BindForm(t *testing.T) {
+	r := multipartRequest(t,
+		testPart{field: "name", content: []byte("John")},
+		testPart{field: "score", content: []byte("1")},
+		testPart{field: "score", content: []byte("2")},
+	)
+
+	form, err := ReadMultipart(r)
+	require.NoError(t, err)
+
+	signup, err := BindForm[signupForm](form.Value)
+
+	require.NoError(t, err)
+	assert.Equal(t, "John", signup.Name)
+	assert.Equal(t, []int{1, 2}, signup.Scores)
+}
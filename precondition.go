@@ -0,0 +1,129 @@
+package httpbox
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// ResourceVersion identifies the current state of a resource. The zero value
+// stands for a resource that does not exist yet
+type ResourceVersion struct {
+	// ETag may be an entity tag or an opaque value such as a revision number,
+	// which is quoted
+	ETag         string
+	LastModified time.Time
+}
+
+func (v ResourceVersion) exists() bool {
+	return v.ETag != "" || !v.LastModified.IsZero()
+}
+
+type PreconditionDetails struct {
+	Header string `json:"header"`
+}
+
+type preconditionConfig struct {
+	required bool
+}
+
+type PreconditionOption func(*preconditionConfig)
+
+// WithPreconditionRequired rejects state-changing requests that have neither
+// If-Match nor If-Unmodified-Since with 428 Precondition Required errors
+func WithPreconditionRequired() PreconditionOption {
+	return func(cfg *preconditionConfig) {
+		cfg.required = true
+	}
+}
+
+// CheckPreconditions evaluates the conditional headers of r against the current
+// version of the resource, in the order defined by RFC 9110. It returns a 412
+// error when a precondition fails, a 304 error when a GET or HEAD request finds
+// the client up to date, and nil when the request can proceed.
+//
+// The validators of current are set on the response, so that they are sent
+// along with 304 responses
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, current ResourceVersion, opts ...PreconditionOption) error {
+	cfg := &preconditionConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	etag := ""
+	if current.ETag != "" {
+		etag = formatETag(current.ETag)
+		w.Header().Set("ETag", etag)
+	}
+
+	lastModified := current.LastModified.Truncate(time.Second)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if cfg.required && !safe {
+		if err := missingPrecondition(r); err != nil {
+			return err
+		}
+	}
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagListMatches(im, etag, current.exists(), etagStrongMatch) {
+			return preconditionFailed("If-Match")
+		}
+	} else if ius, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.After(ius) {
+			return preconditionFailed("If-Unmodified-Since")
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagListMatches(inm, etag, current.exists(), etagWeakMatch) {
+			if safe {
+				return NewError(http.StatusNotModified, "not modified")
+			}
+			return preconditionFailed("If-None-Match")
+		}
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.After(ims) {
+			return NewError(http.StatusNotModified, "not modified")
+		}
+	}
+
+	return nil
+}
+
+func preconditionFailed(header string) *Error {
+	return NewError(http.StatusPreconditionFailed, "precondition failed", WithDetails(PreconditionDetails{Header: header}))
+}
+
+func missingPrecondition(r *http.Request) *Error {
+	if r.Header.Get("If-Match") != "" || r.Header.Get("If-Unmodified-Since") != "" {
+		return nil
+	}
+
+	return NewError(http.StatusPreconditionRequired, "precondition required", WithDetails(PreconditionDetails{Header: "If-Match"}))
+}
+
+// PreconditionRequiredMiddleware rejects requests using one of methods, by
+// default PUT, PATCH and DELETE, that have neither If-Match nor
+// If-Unmodified-Since, forcing clients to avoid lost updates
+func PreconditionRequiredMiddleware(methods ...string) Middleware {
+	if len(methods) == 0 {
+		methods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if slices.Contains(methods, r.Method) {
+				if err := missingPrecondition(r); err != nil {
+					return err
+				}
+			}
+
+			return h(w, r)
+		}
+	}
+}
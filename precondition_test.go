@@ -0,0 +1,139 @@
+package httpbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	current := ResourceVersion{ETag: "v1", LastModified: lastModified}
+
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+	after := lastModified.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		current      ResourceVersion
+		opts         []PreconditionOption
+		expectedCode int
+	}{
+		{"no conditions", http.MethodPut, nil, current, nil, 0},
+		{"If-Match matches", http.MethodPut, map[string]string{"If-Match": `"v1"`}, current, nil, 0},
+		{"If-Match in list", http.MethodPut, map[string]string{"If-Match": `"v0", "v1"`}, current, nil, 0},
+		{"If-Match differs", http.MethodPut, map[string]string{"If-Match": `"v0"`}, current, nil, http.StatusPreconditionFailed},
+		{"If-Match weak", http.MethodPut, map[string]string{"If-Match": `W/"v1"`}, current, nil, http.StatusPreconditionFailed},
+		{"If-Match star", http.MethodDelete, map[string]string{"If-Match": "*"}, current, nil, 0},
+		{"If-Match star missing resource", http.MethodPut, map[string]string{"If-Match": "*"}, ResourceVersion{}, nil, http.StatusPreconditionFailed},
+		{"If-Unmodified-Since passes", http.MethodPut, map[string]string{"If-Unmodified-Since": after}, current, nil, 0},
+		{"If-Unmodified-Since fails", http.MethodPut, map[string]string{"If-Unmodified-Since": before}, current, nil, http.StatusPreconditionFailed},
+		{
+			"If-Match takes precedence over If-Unmodified-Since",
+			http.MethodPut,
+			map[string]string{"If-Match": `"v1"`, "If-Unmodified-Since": before},
+			current, nil, 0,
+		},
+		{"If-None-Match star creates", http.MethodPut, map[string]string{"If-None-Match": "*"}, ResourceVersion{}, nil, 0},
+		{"If-None-Match star exists", http.MethodPut, map[string]string{"If-None-Match": "*"}, current, nil, http.StatusPreconditionFailed},
+		{"If-None-Match GET", http.MethodGet, map[string]string{"If-None-Match": `W/"v1"`}, current, nil, http.StatusNotModified},
+		{"If-None-Match GET differs", http.MethodGet, map[string]string{"If-None-Match": `"v0"`}, current, nil, 0},
+		{"If-Modified-Since GET", http.MethodGet, map[string]string{"If-Modified-Since": after}, current, nil, http.StatusNotModified},
+		{"If-Modified-Since PUT ignored", http.MethodPut, map[string]string{"If-Modified-Since": after}, current, nil, 0},
+		{"required missing", http.MethodPatch, nil, current, []PreconditionOption{WithPreconditionRequired()}, http.StatusPreconditionRequired},
+		{"required GET", http.MethodGet, nil, current, []PreconditionOption{WithPreconditionRequired()}, 0},
+		{
+			"required present",
+			http.MethodPatch,
+			map[string]string{"If-Unmodified-Since": after},
+			current,
+			[]PreconditionOption{WithPreconditionRequired()},
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/test", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			err := CheckPreconditions(httptest.NewRecorder(), req, tt.current, tt.opts...)
+
+			if tt.expectedCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var httpErr *Error
+			require.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tt.expectedCode, httpErr.Code)
+		})
+	}
+}
+
+func TestCheckPreconditions_Response(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return CheckPreconditions(w, r, ResourceVersion{ETag: "v1", LastModified: lastModified})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, `"v1"`, rec.Header().Get("ETag"))
+	assert.Equal(t, "Tue, 02 Jan 2024 03:04:05 GMT", rec.Header().Get("Last-Modified"))
+	assert.Empty(t, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodPut, "/test", nil)
+	req.Header.Set("If-Match", `"v0"`)
+	rec = httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.JSONEq(t, `{"code":412,"message":"precondition failed","details":{"header":"If-Match"}}`, rec.Body.String())
+}
+
+func TestPreconditionRequiredMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		methods      []string
+		header       string
+		expectedCode int
+	}{
+		{"PUT without precondition", http.MethodPut, nil, "", http.StatusPreconditionRequired},
+		{"DELETE with If-Match", http.MethodDelete, nil, "If-Match", http.StatusOK},
+		{"PATCH with If-Unmodified-Since", http.MethodPatch, nil, "If-Unmodified-Since", http.StatusOK},
+		{"POST not covered", http.MethodPost, nil, "", http.StatusOK},
+		{"custom methods", http.MethodPost, []string{http.MethodPost}, "", http.StatusPreconditionRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/test", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, "value")
+			}
+
+			rec := httptest.NewRecorder()
+			okHandler().WithMiddlewares(PreconditionRequiredMiddleware(tt.methods...)).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package httpbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore decides whether a request identified by key fits within the
+// limit. Implementations backed by shared storage allow limiting across
+// several instances of a service
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+type RateLimitKeyFunc func(r *http.Request) (string, error)
+
+// KeyByIP uses the address of the connection. Headers such as X-Forwarded-For
+// are not used because they can be forged unless set by a trusted proxy
+func KeyByIP() RateLimitKeyFunc {
+	return func(r *http.Request) (string, error) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr, nil
+		}
+
+		return host, nil
+	}
+}
+
+// KeyByHeader uses the value of a header such as an API key, falling back to
+// the client IP for requests without it
+func KeyByHeader(header string) RateLimitKeyFunc {
+	byIP := KeyByIP()
+
+	return func(r *http.Request) (string, error) {
+		if value := r.Header.Get(header); value != "" {
+			return fmt.Sprintf("header:%s", value), nil
+		}
+
+		ip, err := byIP(r)
+		return fmt.Sprintf("ip:%s", ip), err
+	}
+}
+
+// KeyByPrincipal limits authenticated users individually, falling back to the
+// client IP for anonymous requests. It must run after an authentication middleware
+func KeyByPrincipal() RateLimitKeyFunc {
+	byIP := KeyByIP()
+
+	return func(r *http.Request) (string, error) {
+		if principal, ok := PrincipalFromContext(r.Context()); ok {
+			return fmt.Sprintf("principal:%s", principal.Identity()), nil
+		}
+
+		ip, err := byIP(r)
+		return fmt.Sprintf("ip:%s", ip), err
+	}
+}
+
+type rateLimitConfig struct {
+	store   RateLimitStore
+	keyFunc RateLimitKeyFunc
+	prefix  string
+}
+
+type RateLimitOption func(*rateLimitConfig)
+
+func WithRateLimitStore(store RateLimitStore) RateLimitOption {
+	return func(cfg *rateLimitConfig) {
+		cfg.store = store
+	}
+}
+
+func WithRateLimitKey(keyFunc RateLimitKeyFunc) RateLimitOption {
+	return func(cfg *rateLimitConfig) {
+		cfg.keyFunc = keyFunc
+	}
+}
+
+// WithRateLimitPrefix namespaces the keys, allowing several limiters with
+// different limits to share a store
+func WithRateLimitPrefix(prefix string) RateLimitOption {
+	return func(cfg *rateLimitConfig) {
+		cfg.prefix = prefix
+	}
+}
+
+func RateLimitMiddleware(limit RateLimit, opts ...RateLimitOption) Middleware {
+	cfg := &rateLimitConfig{
+		keyFunc: KeyByIP(),
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.store == nil {
+		cfg.store = NewTokenBucketStore()
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			key, err := cfg.keyFunc(r)
+			if err != nil {
+				return err
+			}
+
+			result, err := cfg.store.Allow(r.Context(), cfg.prefix+key, limit)
+			if err != nil {
+				return NewError(http.StatusInternalServerError, "Unexpected error occurred",
+					WithInternalError(err),
+					WithLog(),
+				)
+			}
+
+			header := w.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+			if !result.Allowed {
+				retryAfter := ceilSeconds(result.RetryAfter)
+				header.Set("Retry-After", strconv.Itoa(retryAfter))
+
+				return NewError(http.StatusTooManyRequests, "rate limit exceeded", WithDetails(map[string]int{
+					"retry_after": retryAfter,
+				}))
+			}
+
+			return h(w, r)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+const rateLimitShards = 64
+
+// Entries idle for longer than this multiple of their window are dropped
+const rateLimitIdleWindows = 2
+
+type rateLimitShard[E any] struct {
+	mu        sync.Mutex
+	entries   map[string]*E
+	lastSweep time.Time
+}
+
+type shardedRateLimitStore[E any] struct {
+	seed   maphash.Seed
+	shards [rateLimitShards]rateLimitShard[E]
+	now    func() time.Time
+}
+
+func (s *shardedRateLimitStore[E]) init() {
+	s.seed = maphash.MakeSeed()
+	s.now = time.Now
+
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*E)
+	}
+}
+
+func (s *shardedRateLimitStore[E]) shard(key string) *rateLimitShard[E] {
+	return &s.shards[maphash.String(s.seed, key)%rateLimitShards]
+}
+
+// sweep removes idle entries so the store does not grow with every client ever
+// seen. It runs at most once per window and per shard
+func (shard *rateLimitShard[E]) sweep(now time.Time, window time.Duration, idle func(e *E) bool) {
+	if now.Sub(shard.lastSweep) < window {
+		return
+	}
+	shard.lastSweep = now
+
+	for key, entry := range shard.entries {
+		if idle(entry) {
+			delete(shard.entries, key)
+		}
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type TokenBucketStore struct {
+	shardedRateLimitStore[tokenBucket]
+}
+
+// NewTokenBucketStore returns an in-memory store where each key has a bucket of
+// limit.Requests tokens refilled continuously over limit.Window, allowing bursts
+// up to the bucket size
+func NewTokenBucketStore() *TokenBucketStore {
+	store := &TokenBucketStore{}
+	store.init()
+	return store
+}
+
+func (s *TokenBucketStore) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	if limit.Requests <= 0 || limit.Window <= 0 {
+		return RateLimitResult{}, errInvalidRateLimit
+	}
+
+	now := s.now()
+	capacity := float64(limit.Requests)
+	perToken := limit.Window / time.Duration(limit.Requests)
+
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.sweep(now, limit.Window, func(b *tokenBucket) bool {
+		return now.Sub(b.last) > rateLimitIdleWindows*limit.Window
+	})
+
+	bucket, ok := shard.entries[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		shard.entries[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last)
+	bucket.tokens = min(capacity, bucket.tokens+elapsed.Seconds()/perToken.Seconds())
+	bucket.last = now
+
+	result := RateLimitResult{Limit: limit.Requests}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) * float64(perToken))
+	}
+
+	result.Remaining = int(bucket.tokens)
+	result.ResetAfter = time.Duration((capacity - bucket.tokens) * float64(perToken))
+
+	return result, nil
+}
+
+type slidingWindow struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+type SlidingWindowStore struct {
+	shardedRateLimitStore[slidingWindow]
+}
+
+// NewSlidingWindowStore returns an in-memory store approximating a sliding
+// window by weighting the count of the previous fixed window with how much of
+// it still overlaps the sliding one
+func NewSlidingWindowStore() *SlidingWindowStore {
+	store := &SlidingWindowStore{}
+	store.init()
+	return store
+}
+
+func (s *SlidingWindowStore) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	if limit.Requests <= 0 || limit.Window <= 0 {
+		return RateLimitResult{}, errInvalidRateLimit
+	}
+
+	now := s.now()
+
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.sweep(now, limit.Window, func(sw *slidingWindow) bool {
+		return now.Sub(sw.start) > rateLimitIdleWindows*limit.Window
+	})
+
+	window, ok := shard.entries[key]
+	if !ok {
+		window = &slidingWindow{start: now.Truncate(limit.Window)}
+		shard.entries[key] = window
+	}
+
+	if elapsed := now.Sub(window.start); elapsed >= limit.Window {
+		if elapsed < 2*limit.Window {
+			window.previous = window.current
+		} else {
+			window.previous = 0
+		}
+		window.current = 0
+		window.start = now.Truncate(limit.Window)
+	}
+
+	elapsed := now.Sub(window.start)
+	weight := 1 - float64(elapsed)/float64(limit.Window)
+	count := float64(window.previous)*weight + float64(window.current)
+
+	result := RateLimitResult{
+		Limit:      limit.Requests,
+		ResetAfter: limit.Window - elapsed,
+	}
+
+	if count+1 <= float64(limit.Requests) {
+		window.current++
+		count++
+		result.Allowed = true
+	} else if window.previous > 0 && float64(window.current)+1 <= float64(limit.Requests) {
+		// Time until enough of the previous window slides out to make room
+		needed := (count + 1 - float64(limit.Requests)) / float64(window.previous)
+		result.RetryAfter = time.Duration(needed * float64(limit.Window))
+	} else {
+		result.RetryAfter = limit.Window - elapsed
+	}
+
+	result.Remaining = max(0, limit.Requests-int(math.Ceil(count)))
+
+	return result, nil
+}
+
+var errInvalidRateLimit = errors.New("httpbox: rate limit requires positive requests and window")
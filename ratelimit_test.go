@@ -0,0 +1,187 @@
+package httpbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestTokenBucketStore(t *testing.T) {
+	clock := newFakeClock()
+	store := NewTokenBucketStore()
+	store.now = clock.Now
+	limit := RateLimit{Requests: 2, Window: 2 * time.Second}
+
+	first, err := store.Allow(context.Background(), "k", limit)
+	require.NoError(t, err)
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 1, first.Remaining)
+
+	second, _ := store.Allow(context.Background(), "k", limit)
+	assert.True(t, second.Allowed)
+	assert.Equal(t, 0, second.Remaining)
+
+	third, _ := store.Allow(context.Background(), "k", limit)
+	assert.False(t, third.Allowed)
+	assert.Equal(t, time.Second, third.RetryAfter)
+
+	other, _ := store.Allow(context.Background(), "other", limit)
+	assert.True(t, other.Allowed, "keys must be limited independently")
+
+	clock.Advance(time.Second)
+
+	refilled, _ := store.Allow(context.Background(), "k", limit)
+	assert.True(t, refilled.Allowed)
+}
+
+func TestSlidingWindowStore(t *testing.T) {
+	clock := newFakeClock()
+	store := NewSlidingWindowStore()
+	store.now = clock.Now
+	limit := RateLimit{Requests: 2, Window: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		result, err := store.Allow(context.Background(), "k", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	}
+
+	denied, _ := store.Allow(context.Background(), "k", limit)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, 0, denied.Remaining)
+	assert.Equal(t, time.Minute, denied.RetryAfter)
+
+	// Half of the previous window still counts, so one of its two requests remains
+	clock.Advance(90 * time.Second)
+
+	allowed, _ := store.Allow(context.Background(), "k", limit)
+	assert.True(t, allowed.Allowed)
+
+	denied, _ = store.Allow(context.Background(), "k", limit)
+	assert.False(t, denied.Allowed)
+
+	clock.Advance(2 * time.Minute)
+
+	allowed, _ = store.Allow(context.Background(), "k", limit)
+	assert.True(t, allowed.Allowed)
+}
+
+func TestRateLimitStores_InvalidLimit(t *testing.T) {
+	stores := map[string]RateLimitStore{
+		"token bucket":   NewTokenBucketStore(),
+		"sliding window": NewSlidingWindowStore(),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Allow(context.Background(), "k", RateLimit{})
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTokenBucketStore_SweepsIdleEntries(t *testing.T) {
+	clock := newFakeClock()
+	store := NewTokenBucketStore()
+	store.now = clock.Now
+	limit := RateLimit{Requests: 1, Window: time.Second}
+
+	for i := 0; i < 100; i++ {
+		store.Allow(context.Background(), fmt.Sprintf("client-%d", i), limit)
+	}
+
+	clock.Advance(time.Minute)
+	store.Allow(context.Background(), "client-0", limit)
+
+	// Sweeping is done per shard, so only the shard of the key being used is cleaned
+	assert.Len(t, store.shard("client-0").entries, 1)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte("ok"))
+	}).WithMiddlewares(RateLimitMiddleware(RateLimit{Requests: 1, Window: time.Minute}))
+
+	first := httptest.NewRecorder()
+	h.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "1", first.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", first.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", first.Header().Get("RateLimit-Reset"))
+
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+	assert.Equal(t, "60", second.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":429,"message":"rate limit exceeded","details":{"retry_after":60}}`, second.Body.String())
+}
+
+func TestRateLimitMiddleware_KeyByHeader(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}).WithMiddlewares(RateLimitMiddleware(
+		RateLimit{Requests: 1, Window: time.Minute},
+		WithRateLimitKey(KeyByHeader("X-API-Key")),
+	))
+
+	codes := make([]int, 0, 4)
+	for _, key := range []string{"a", "b", "a", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		codes = append(codes, rec.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK}, codes)
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store unavailable")
+}
+
+func TestRateLimitMiddleware_StoreError(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}).WithMiddlewares(RateLimitMiddleware(
+		RateLimit{Requests: 1, Window: time.Minute},
+		WithRateLimitStore(failingRateLimitStore{}),
+	))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -3,6 +3,7 @@ package httpbox
 import (
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"io"
 	"net/http"
 )
@@ -20,11 +21,46 @@ func verifyValidator(v any) error {
 	return validator.Validate()
 }
 
+// readError reports a body that could not be read or decoded as a bad request,
+// unless the body reader already failed with an *Error, such as the 413 errors
+// of bodies decompressed by DecompressionMiddleware
+func readError(err error, message string) error {
+	var httpErr *Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	// Bodies limited with http.MaxBytesReader
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return NewError(http.StatusRequestEntityTooLarge, "request body too large")
+	}
+
+	return NewError(http.StatusBadRequest, message, WithDetails(err))
+}
+
+// itemError ties err to an item of a streamed body, such as a line or an array
+// element, with the details built from the error message and details. *Error
+// values are copied along with all their fields, other errors are reported as
+// bad requests with message
+func itemError(message string, err error, details func(errMessage string, errDetails any) any) *Error {
+	var httpErr *Error
+	if !errors.As(err, &httpErr) {
+		return NewError(http.StatusBadRequest, message, WithDetails(details(err.Error(), nil)))
+	}
+
+	// The copy leaves values shared by several requests untouched
+	itemErr := *httpErr
+	itemErr.Details = details(httpErr.Message, httpErr.Details)
+
+	return &itemErr
+}
+
 func ReadJSON[T any](r io.Reader) (T, error) {
 	var v T
 
 	if err := json.NewDecoder(r).Decode(&v); err != nil {
-		return v, NewError(http.StatusBadRequest, "invalid JSON body", WithDetails(err))
+		return v, readError(err, "invalid JSON body")
 	}
 
 	if err := verifyValidator(v); err != nil {
@@ -38,7 +74,7 @@ func ReadXML[T any](r io.Reader) (T, error) {
 	var v T
 
 	if err := xml.NewDecoder(r).Decode(&v); err != nil {
-		return v, NewError(http.StatusBadRequest, "invalid XML body", WithDetails(err))
+		return v, readError(err, "invalid XML body")
 	}
 
 	if err := verifyValidator(v); err != nil {
@@ -51,7 +87,7 @@ func ReadXML[T any](r io.Reader) (T, error) {
 func ReadBytes(r io.Reader) ([]byte, error) {
 	data, err := io.ReadAll(r)
 	if err != nil {
-		return nil, NewError(http.StatusBadRequest, "unable to read body", WithDetails(err))
+		return nil, readError(err, "unable to read body")
 	}
 
 	return data, nil
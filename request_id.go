@@ -0,0 +1,153 @@
+package httpbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// Incoming IDs longer than this are replaced by a generated one, as they end
+// up verbatim in logs and response headers
+const maxRequestIDLength = 128
+
+type requestIDConfig struct {
+	header    string
+	generator func() string
+	trust     bool
+	inError   bool
+}
+
+type RequestIDOption func(*requestIDConfig)
+
+func WithRequestIDHeader(header string) RequestIDOption {
+	return func(cfg *requestIDConfig) {
+		cfg.header = header
+	}
+}
+
+func WithRequestIDGenerator(generator func() string) RequestIDOption {
+	return func(cfg *requestIDConfig) {
+		cfg.generator = generator
+	}
+}
+
+// WithoutIncomingRequestID ignores IDs sent by clients and always generates a
+// new one. Useful when the service is not behind a trusted proxy
+func WithoutIncomingRequestID() RequestIDOption {
+	return func(cfg *requestIDConfig) {
+		cfg.trust = false
+	}
+}
+
+func WithRequestIDInError() RequestIDOption {
+	return func(cfg *requestIDConfig) {
+		cfg.inError = true
+	}
+}
+
+func RequestIDMiddleware(opts ...RequestIDOption) Middleware {
+	cfg := &requestIDConfig{
+		header:    DefaultRequestIDHeader,
+		generator: NewUUIDv7,
+		trust:     true,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			id := ""
+			if cfg.trust {
+				id = r.Header.Get(cfg.header)
+			}
+
+			if !isValidRequestID(id) {
+				id = cfg.generator()
+			}
+
+			w.Header().Set(cfg.header, id)
+
+			r, state := withRequestState(r)
+			state.requestID = id
+			state.requestIDInError = cfg.inError
+
+			return h(w, r)
+		}
+	}
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	state := requestStateFrom(ctx)
+	if state == nil {
+		return ""
+	}
+
+	return state.requestID
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	// Only visible ASCII characters are accepted to prevent header and log injection
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func NewUUIDv7() string {
+	var b [16]byte
+
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	b[6] = (b[6] & 0x0f) | 0x70 // Version 7
+	b[8] = (b[8] & 0x3f) | 0x80 // Variant 10
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+
+	return string(buf[:])
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func NewULID() string {
+	var b [16]byte
+
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	// 128 bits are encoded as 26 base32 characters, the first one only holding 3 bits
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[lo&0x1f]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+
+	return string(buf[:])
+}
@@ -0,0 +1,191 @@
+package httpbox
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer is safe for concurrent use, as some middlewares log from their own
+// goroutines
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (lb *logBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.Write(p)
+}
+
+func (lb *logBuffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.String()
+}
+
+func captureLogs(t *testing.T) *logBuffer {
+	t.Helper()
+
+	buf := &logBuffer{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return buf
+}
+
+func TestRequestIDMiddleware_GeneratesID(t *testing.T) {
+	var seen string
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		seen = RequestIDFromContext(r.Context())
+		return nil
+	}).WithMiddlewares(RequestIDMiddleware())
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	require.NotEmpty(t, seen)
+	assert.Equal(t, seen, rec.Header().Get(DefaultRequestIDHeader))
+}
+
+func TestRequestIDMiddleware_IncomingID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		opts     []RequestIDOption
+		kept     bool
+	}{
+		{"valid ID is kept", "abc-123", nil, true},
+		{"ID with spaces is replaced", "abc 123", nil, false},
+		{"ID with newline is replaced", "abc\n123", nil, false},
+		{"too long ID is replaced", strings.Repeat("a", 129), nil, false},
+		{"incoming ID ignored", "abc-123", []RequestIDOption{WithoutIncomingRequestID()}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				seen = RequestIDFromContext(r.Context())
+				return nil
+			}).WithMiddlewares(RequestIDMiddleware(tt.opts...))
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set(DefaultRequestIDHeader, tt.incoming)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			if tt.kept {
+				assert.Equal(t, tt.incoming, seen)
+			} else {
+				assert.NotEqual(t, tt.incoming, seen)
+				assert.NotEmpty(t, seen)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware_CustomHeaderAndGenerator(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}).WithMiddlewares(RequestIDMiddleware(
+		WithRequestIDHeader("X-Correlation-ID"),
+		WithRequestIDGenerator(func() string { return "fixed" }),
+	))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "fixed", rec.Header().Get("X-Correlation-ID"))
+	assert.Empty(t, rec.Header().Get(DefaultRequestIDHeader))
+}
+
+func TestRequestIDMiddleware_InError(t *testing.T) {
+	notFound := NewError(http.StatusNotFound, "not found")
+
+	tests := []struct {
+		name         string
+		opts         []RequestIDOption
+		expectedBody string
+	}{
+		{
+			name:         "not embedded by default",
+			expectedBody: `{"code":404,"message":"not found"}`,
+		},
+		{
+			name:         "embedded when enabled",
+			opts:         []RequestIDOption{WithRequestIDInError()},
+			expectedBody: `{"code":404,"message":"not found","request_id":"req-1"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				return notFound
+			}).WithMiddlewares(RequestIDMiddleware(tt.opts...))
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set(DefaultRequestIDHeader, "req-1")
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+			assert.Empty(t, notFound.RequestID, "shared errors must not be mutated")
+		})
+	}
+}
+
+func TestRequestIDMiddleware_Logs(t *testing.T) {
+	logs := captureLogs(t)
+
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return NewError(http.StatusInternalServerError, "boom", WithLog())
+	}).WithMiddlewares(RequestIDMiddleware(), AccessLogMiddleware())
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(DefaultRequestIDHeader, "req-1")
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, logs.String(), "msg=boom request_id=req-1")
+	assert.Contains(t, logs.String(), "msg=Access request_id=req-1")
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	first := NewUUIDv7()
+	second := NewUUIDv7()
+
+	assert.Regexp(t, pattern, first)
+	assert.Regexp(t, pattern, second)
+	assert.NotEqual(t, first, second)
+}
+
+func TestNewULID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+
+	first := NewULID()
+	second := NewULID()
+
+	assert.Regexp(t, pattern, first)
+	assert.Regexp(t, pattern, second)
+	assert.NotEqual(t, first, second)
+}
@@ -12,6 +12,7 @@ type paramFrom string
 const (
 	fromPath  paramFrom = "URL path"
 	fromQuery paramFrom = "URL query string"
+	fromForm  paramFrom = "form"
 )
 
 type Param struct {
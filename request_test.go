@@ -3,6 +3,7 @@ package httpbox
 import (
 	"bytes"
 	"errors"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -413,3 +414,26 @@ func TestReadJSON_WithExtraFields(t *testing.T) {
 	assert.Equal(t, "john@example.com", result.Email)
 	assert.Equal(t, 30, result.Age)
 }
+
+func TestItemError(t *testing.T) {
+	details := func(errMessage string, errDetails any) any {
+		return LineErrorDetails{Line: 3, Error: errMessage, Details: errDetails}
+	}
+
+	err := itemError("invalid item", errors.New("bad value"), details)
+
+	assert.Equal(t, http.StatusBadRequest, err.Code)
+	assert.Equal(t, "invalid item", err.Message)
+	assert.Equal(t, LineErrorDetails{Line: 3, Error: "bad value"}, err.Details)
+
+	shared := NewError(http.StatusInternalServerError, "lookup failed", WithDetails("db"), WithInternalError(errors.New("db down")), WithLog())
+
+	err = itemError("invalid item", shared, details)
+
+	assert.Equal(t, http.StatusInternalServerError, err.Code)
+	assert.Equal(t, "lookup failed", err.Message)
+	assert.True(t, err.Log)
+	assert.EqualError(t, err.Err, "db down")
+	assert.Equal(t, LineErrorDetails{Line: 3, Error: "lookup failed", Details: "db"}, err.Details)
+	assert.Equal(t, "db", shared.Details)
+}
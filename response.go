@@ -8,7 +8,18 @@ import (
 	"net/http"
 )
 
-func WriteJSON(w http.ResponseWriter, code int, data any) error {
+// WriteJSON encodes data as the response body. When options are given, the
+// body is buffered to apply them before anything is sent
+func WriteJSON(w http.ResponseWriter, code int, data any, opts ...ResponseOption) error {
+	if len(opts) > 0 {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(data); err != nil {
+			return err
+		}
+
+		return writeBody(w, code, "application/json", buf.Bytes(), opts)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 
@@ -19,7 +30,16 @@ func WriteJSON(w http.ResponseWriter, code int, data any) error {
 	return nil
 }
 
-func WriteXML(w http.ResponseWriter, code int, data any) error {
+func WriteXML(w http.ResponseWriter, code int, data any, opts ...ResponseOption) error {
+	if len(opts) > 0 {
+		var buf bytes.Buffer
+		if err := xml.NewEncoder(&buf).Encode(data); err != nil {
+			return err
+		}
+
+		return writeBody(w, code, "application/xml", buf.Bytes(), opts)
+	}
+
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(code)
 
@@ -30,8 +50,8 @@ func WriteXML(w http.ResponseWriter, code int, data any) error {
 	return nil
 }
 
-func WriteBytes(w http.ResponseWriter, code int, contentType string, data []byte) error {
-	return WriteFromReader(w, bytes.NewReader(data), code, contentType)
+func WriteBytes(w http.ResponseWriter, code int, contentType string, data []byte, opts ...ResponseOption) error {
+	return writeBody(w, code, contentType, data, opts)
 }
 
 func WriteFromReader(w http.ResponseWriter, r io.Reader, code int, contentType string) error {
@@ -0,0 +1,37 @@
+package httpbox
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// responseBuffer is an in-memory http.ResponseWriter used to capture the
+// response of handlers called by httpbox itself
+type responseBuffer struct {
+	header      http.Header
+	statusCode  int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: make(http.Header), statusCode: http.StatusOK}
+}
+
+func (rb *responseBuffer) Header() http.Header {
+	return rb.header
+}
+
+func (rb *responseBuffer) WriteHeader(statusCode int) {
+	if rb.wroteHeader {
+		return
+	}
+
+	rb.statusCode = statusCode
+	rb.wroteHeader = true
+}
+
+func (rb *responseBuffer) Write(b []byte) (int, error) {
+	rb.wroteHeader = true
+	return rb.body.Write(b)
+}
@@ -0,0 +1,359 @@
+package httpbox
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Route struct {
+	Name    string
+	Method  string
+	Host    string
+	Path    string
+	Pattern string
+	Mounted bool
+
+	tree *routerTree
+	// middlewares are those of the router the route was registered with,
+	// also run by the automatic OPTIONS and 405 responses of its path
+	middlewares []Middleware
+}
+
+// Named gives the route a name to build its URL with Router.URLFor. It panics
+// if another route already uses the name
+func (route *Route) Named(name string) *Route {
+	if route.tree == nil {
+		panic("httpbox: only routes returned by a Router can be named")
+	}
+
+	route.tree.mu.Lock()
+	defer route.tree.mu.Unlock()
+
+	if other, ok := route.tree.names[name]; ok && other != route {
+		panic(fmt.Sprintf("httpbox: route name %q already used by %q", name, other.Pattern))
+	}
+
+	delete(route.tree.names, route.Name)
+	route.Name = name
+	route.tree.names[name] = route
+
+	return route
+}
+
+type routerTree struct {
+	mux *http.ServeMux
+
+	mu       sync.RWMutex
+	routes   []*Route
+	names    map[string]*Route
+	patterns map[string]*Route
+	config   routerConfig
+}
+
+// Router wraps http.ServeMux, keeping its pattern syntax, to register routes in
+// groups sharing a path prefix and middlewares
+type Router struct {
+	tree        *routerTree
+	prefix      string
+	middlewares []Middleware
+	sealed      bool
+}
+
+type routerConfig struct {
+	autoHead    bool
+	autoOptions bool
+}
+
+type RouterOption func(*routerConfig)
+
+// WithoutAutoHead lets GET handlers receive HEAD requests unchanged. Without
+// it, their body is discarded while keeping the Content-Length it would have
+func WithoutAutoHead() RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.autoHead = false
+	}
+}
+
+// WithoutAutoOptions makes OPTIONS requests to routes without an explicit
+// OPTIONS handler fail with 405 instead of being answered with the Allow header
+func WithoutAutoOptions() RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.autoOptions = false
+	}
+}
+
+// Registering a HEAD or OPTIONS route for a path overrides the automatic
+// handling for that path, as ServeMux prefers the more specific method
+func NewRouter(opts ...RouterOption) *Router {
+	cfg := routerConfig{
+		autoHead:    true,
+		autoOptions: true,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Router{
+		tree: &routerTree{
+			mux:      http.NewServeMux(),
+			names:    make(map[string]*Route),
+			patterns: make(map[string]*Route),
+			config:   cfg,
+		},
+	}
+}
+
+// Use adds middlewares to every route registered afterwards in this router and
+// its groups. It panics once routes or groups were registered, since they
+// would silently miss the middlewares.
+//
+// These middlewares run once the route is matched, which those keyed by route
+// pattern need, such as RoutePolicyMiddleware, WithRouteTimeout and
+// WithConcurrencyPerRoute. Wrapping the Router itself, they only see the
+// pattern after calling the next handler
+func (rt *Router) Use(middlewares ...Middleware) {
+	if rt.sealed {
+		panic("httpbox: Router.Use must be called before registering routes")
+	}
+
+	rt.middlewares = append(rt.middlewares, middlewares...)
+}
+
+func (rt *Router) Handle(pattern string, h Handler, middlewares ...Middleware) *Route {
+	method, host, path := splitPattern(pattern)
+
+	route := &Route{
+		Method: method,
+		Host:   host,
+		Path:   rt.prefix + path,
+	}
+	route.Pattern = joinPattern(route.Method, route.Host, route.Path)
+
+	rt.register(route, h.WithMiddlewares(middlewares...))
+
+	return route
+}
+
+func (rt *Router) register(route *Route, h Handler) {
+	rt.sealed = true
+	route.tree = rt.tree
+	route.middlewares = rt.middlewares
+
+	h = h.WithMiddlewares(rt.middlewares...)
+
+	rt.tree.mu.Lock()
+	defer rt.tree.mu.Unlock()
+
+	rt.tree.mux.Handle(route.Pattern, h)
+	rt.tree.routes = append(rt.tree.routes, route)
+	rt.tree.patterns[route.Pattern] = route
+}
+
+// Group returns a router registering its routes below prefix, with the
+// middlewares of rt followed by the given ones
+func (rt *Router) Group(prefix string, middlewares ...Middleware) *Router {
+	rt.sealed = true
+
+	return &Router{
+		tree:        rt.tree,
+		prefix:      rt.prefix + strings.TrimSuffix(prefix, "/"),
+		middlewares: append(append([]Middleware(nil), rt.middlewares...), middlewares...),
+	}
+}
+
+// Mount serves every request below prefix with h, which receives the path with
+// the prefix stripped. Mounting another Router keeps its own 404 and 405 handling
+func (rt *Router) Mount(prefix string, h http.Handler) *Route {
+	fullPrefix := rt.prefix + strings.TrimSuffix(prefix, "/")
+
+	route := &Route{
+		Path:    fullPrefix + "/",
+		Pattern: fullPrefix + "/",
+		Mounted: true,
+	}
+
+	rt.register(route, AdaptHandler(http.StripPrefix(fullPrefix, h)))
+
+	return route
+}
+
+func (rt *Router) Routes() []Route {
+	rt.tree.mu.RLock()
+	defer rt.tree.mu.RUnlock()
+
+	routes := make([]Route, len(rt.tree.routes))
+	for i, route := range rt.tree.routes {
+		routes[i] = *route
+		routes[i].tree = nil
+		routes[i].middlewares = nil
+	}
+
+	return routes
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, pattern := rt.tree.mux.Handler(r)
+
+	if pattern != "" && r.Method == http.MethodHead && rt.tree.config.autoHead && strings.HasPrefix(pattern, "GET ") {
+		hw := &headResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		rt.tree.mux.ServeHTTP(hw, r)
+		hw.commit(true)
+		return
+	}
+
+	if pattern != "" {
+		rt.tree.mux.ServeHTTP(w, r)
+		return
+	}
+
+	// The mux handler is run against a buffer to learn whether the path exists
+	// with other methods, along with the Allow header it computes
+	buf := newResponseBuffer()
+	h.ServeHTTP(buf, r)
+
+	middlewares := rt.middlewares
+	var allow []string
+	for method := range strings.SplitSeq(buf.header.Get("Allow"), ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			allow = append(allow, method)
+		}
+	}
+
+	if buf.statusCode == http.StatusMethodNotAllowed {
+		if route := rt.tree.routeAllowing(r, allow); route != nil {
+			middlewares = route.middlewares
+		}
+	}
+
+	rt.unmatched(buf.statusCode, allow).WithMiddlewares(middlewares...).ServeHTTP(w, r)
+}
+
+// routeAllowing returns a route registered for the path of r with one of the
+// allowed methods, whose group middlewares apply to the path
+func (tree *routerTree) routeAllowing(r *http.Request, allow []string) *Route {
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+
+	for _, method := range allow {
+		r2 := *r
+		r2.Method = method
+
+		if _, pattern := tree.mux.Handler(&r2); pattern != "" {
+			if route, ok := tree.patterns[pattern]; ok {
+				return route
+			}
+		}
+	}
+
+	return nil
+}
+
+// unmatched renders the responses ServeMux produces for unmatched requests as
+// errors
+func (rt *Router) unmatched(statusCode int, allow []string) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if statusCode == http.StatusMethodNotAllowed {
+			if rt.tree.config.autoOptions && !slices.Contains(allow, http.MethodOptions) {
+				allow = append(allow, http.MethodOptions)
+			}
+
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+
+			if r.Method == http.MethodOptions && rt.tree.config.autoOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return nil
+			}
+
+			return NewError(http.StatusMethodNotAllowed, "method not allowed")
+		}
+
+		return NewError(http.StatusNotFound, "route not found")
+	}
+}
+
+// splitPattern splits a ServeMux pattern "[METHOD ][HOST]/[PATH]"
+func splitPattern(pattern string) (method, host, path string) {
+	rest := strings.TrimLeft(pattern, " \t")
+
+	if i := strings.IndexAny(rest, " \t"); i >= 0 {
+		method, rest = rest[:i], strings.TrimLeft(rest[i:], " \t")
+	}
+
+	i := strings.IndexByte(rest, '/')
+	if i < 0 {
+		panic(fmt.Sprintf("httpbox: invalid route pattern %q: host/path missing /", pattern))
+	}
+
+	return method, rest[:i], rest[i:]
+}
+
+func joinPattern(method, host, path string) string {
+	if method == "" {
+		return host + path
+	}
+
+	return method + " " + host + path
+}
+
+// headResponseWriter discards the body written by GET handlers serving HEAD
+// requests. The status is held back until the handler returns so that the
+// Content-Length matches the body a GET request would receive
+type headResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	committed   bool
+	size        int
+}
+
+func (hw *headResponseWriter) WriteHeader(statusCode int) {
+	if statusCode < 200 {
+		hw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	if !hw.wroteHeader {
+		hw.statusCode = statusCode
+		hw.wroteHeader = true
+	}
+}
+
+func (hw *headResponseWriter) Write(b []byte) (int, error) {
+	hw.wroteHeader = true
+	hw.size += len(b)
+	return len(b), nil
+}
+
+// Flush commits the response since the handler is streaming, in which case the
+// final size cannot be known and Content-Length is left unset
+func (hw *headResponseWriter) Flush() {
+	hw.commit(false)
+	http.NewResponseController(hw.ResponseWriter).Flush()
+}
+
+func (hw *headResponseWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+// commit sends the status, with the Content-Length of the discarded body when
+// sizeKnown is set, that is once the handler returned
+func (hw *headResponseWriter) commit(sizeKnown bool) {
+	if hw.committed {
+		return
+	}
+	hw.committed = true
+
+	header := hw.ResponseWriter.Header()
+	bodyAllowed := hw.statusCode != http.StatusNoContent && hw.statusCode != http.StatusNotModified
+
+	if sizeKnown && bodyAllowed && header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" {
+		header.Set("Content-Length", strconv.Itoa(hw.size))
+	}
+
+	hw.ResponseWriter.WriteHeader(hw.statusCode)
+}
@@ -0,0 +1,313 @@
+package httpbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func headerMiddleware(name string) Middleware {
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Add("X-Middleware", name)
+			return h(w, r)
+		}
+	}
+}
+
+func textHandler(body string) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte(body))
+	}
+}
+
+func serveRouter(rt http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestRouter_Handle(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte(r.PathValue("id")+" "+r.Pattern))
+	})
+
+	rec := serveRouter(rt, http.MethodGet, "/users/42")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "42 GET /users/{id}", rec.Body.String())
+}
+
+func TestRouter_GroupsAndMiddlewares(t *testing.T) {
+	rt := NewRouter()
+	rt.Use(headerMiddleware("root"))
+	rt.Handle("GET /health", textHandler("health"))
+
+	api := rt.Group("/api/", headerMiddleware("api"))
+	api.Handle("GET /users", textHandler("users"), headerMiddleware("route"))
+
+	admin := api.Group("/admin", headerMiddleware("admin"))
+	admin.Handle("DELETE /users/{id}", textHandler("deleted"))
+
+	tests := []struct {
+		method      string
+		target      string
+		body        string
+		middlewares []string
+	}{
+		{http.MethodGet, "/health", "health", []string{"root"}},
+		{http.MethodGet, "/api/users", "users", []string{"root", "api", "route"}},
+		{http.MethodDelete, "/api/admin/users/1", "deleted", []string{"root", "api", "admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serveRouter(rt, tt.method, tt.target)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.body, rec.Body.String())
+			assert.Equal(t, tt.middlewares, rec.Header().Values("X-Middleware"))
+		})
+	}
+}
+
+func TestRouter_UseAfterRoutesPanics(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /", textHandler("root"))
+
+	assert.Panics(t, func() { rt.Use(headerMiddleware("late")) })
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	rt := NewRouter()
+	rt.Use(headerMiddleware("root"))
+	rt.Handle("GET /users", textHandler("users"))
+
+	rec := serveRouter(rt, http.MethodGet, "/missing")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"code":404,"message":"route not found"}`, rec.Body.String())
+	assert.Equal(t, []string{"root"}, rec.Header().Values("X-Middleware"))
+}
+
+func TestRouter_MethodNotAllowed(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /users", textHandler("list"))
+	rt.Handle("POST /users", textHandler("create"))
+
+	rec := serveRouter(rt, http.MethodDelete, "/users")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD, POST, OPTIONS", rec.Header().Get("Allow"))
+	assert.JSONEq(t, `{"code":405,"message":"method not allowed"}`, rec.Body.String())
+}
+
+func TestRouter_Mount(t *testing.T) {
+	sub := NewRouter()
+	sub.Handle("GET /status", func(w http.ResponseWriter, r *http.Request) error {
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte("status at "+r.URL.Path))
+	})
+
+	rt := NewRouter()
+	rt.Group("/v1").Mount("/admin/", sub)
+	rt.Mount("/files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("file " + r.URL.Path))
+	}))
+
+	rec := serveRouter(rt, http.MethodGet, "/v1/admin/status")
+	assert.Equal(t, "status at /status", rec.Body.String())
+
+	rec = serveRouter(rt, http.MethodGet, "/v1/admin/unknown")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json"))
+
+	rec = serveRouter(rt, http.MethodGet, "/files/a/b.txt")
+	assert.Equal(t, "file /a/b.txt", rec.Body.String())
+}
+
+func TestRouter_Routes(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /{$}", textHandler("root"))
+	api := rt.Group("/api")
+	api.Handle("POST example.com/users", textHandler("create"))
+	api.Mount("/legacy", http.NotFoundHandler())
+
+	routes := rt.Routes()
+
+	require.Len(t, routes, 3)
+	assert.Equal(t, Route{Method: "GET", Path: "/{$}", Pattern: "GET /{$}"}, routes[0])
+	assert.Equal(t, Route{Method: "POST", Host: "example.com", Path: "/api/users", Pattern: "POST example.com/api/users"}, routes[1])
+	assert.Equal(t, Route{Path: "/api/legacy/", Pattern: "/api/legacy/", Mounted: true}, routes[2])
+	assert.Equal(t, routes, api.Routes())
+}
+
+func TestRouter_InvalidPattern(t *testing.T) {
+	rt := NewRouter()
+
+	assert.Panics(t, func() { rt.Handle("GET users", textHandler("users")) })
+}
+
+func TestRouter_AutoOptions(t *testing.T) {
+	rt := NewRouter()
+	rt.Use(headerMiddleware("root"))
+	rt.Handle("GET /users", textHandler("list"))
+	rt.Handle("POST /users", textHandler("create"))
+	rt.Handle("GET /custom", textHandler("custom"))
+	rt.Handle("OPTIONS /custom", func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	rec := serveRouter(rt, http.MethodOptions, "/users")
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "GET, HEAD, POST, OPTIONS", rec.Header().Get("Allow"))
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, []string{"root"}, rec.Header().Values("X-Middleware"))
+
+	rec = serveRouter(rt, http.MethodOptions, "/custom")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "GET", rec.Header().Get("Allow"))
+
+	rec = serveRouter(rt, http.MethodOptions, "/missing")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRouter_AutoOptionsGroupMiddlewares(t *testing.T) {
+	rt := NewRouter()
+	rt.Use(headerMiddleware("root"))
+
+	api := rt.Group("/api", headerMiddleware("api"))
+	api.Handle("GET /items", textHandler("items"), headerMiddleware("route"))
+
+	rec := serveRouter(rt, http.MethodOptions, "/api/items")
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, []string{"root", "api"}, rec.Header().Values("X-Middleware"))
+
+	rec = serveRouter(rt, http.MethodDelete, "/api/items")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, []string{"root", "api"}, rec.Header().Values("X-Middleware"))
+
+	rec = serveRouter(rt, http.MethodGet, "/api/missing")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, []string{"root"}, rec.Header().Values("X-Middleware"))
+}
+
+func TestRouter_AutoOptionsCORS(t *testing.T) {
+	rt := NewRouter()
+	api := rt.Group("/api", CORSMiddleware(WithCORSOrigins("https://app.example.com"), WithCORSMethods(http.MethodGet, http.MethodPut)))
+	api.Handle("PUT /items", textHandler("updated"))
+
+	r := httptest.NewRequest(http.MethodOptions, "/api/items", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	rec := httptest.NewRecorder()
+
+	rt.ServeHTTP(rec, r)
+
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), http.MethodPut)
+}
+
+func TestRouter_MethodNotAllowedWithOptionsRoute(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /custom", textHandler("custom"))
+	rt.Handle("OPTIONS /custom", textHandler("options"))
+
+	rec := serveRouter(rt, http.MethodPut, "/custom")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", rec.Header().Get("Allow"))
+}
+
+func TestRouter_WithoutAutoOptions(t *testing.T) {
+	rt := NewRouter(WithoutAutoOptions())
+	rt.Handle("GET /users", textHandler("list"))
+
+	rec := serveRouter(rt, http.MethodOptions, "/users")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+}
+
+func TestRouter_AutoHead(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /users", func(w http.ResponseWriter, r *http.Request) error {
+		return WriteJSON(w, http.StatusOK, []string{"alice", "bob"})
+	})
+	rt.Handle("GET /empty", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	rt.Handle("GET /error", func(w http.ResponseWriter, r *http.Request) error {
+		return NewError(http.StatusConflict, "conflict")
+	})
+	rt.Handle("GET /custom", textHandler("get"))
+	rt.Handle("HEAD /custom", func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("X-Custom", "head")
+		return nil
+	})
+
+	get := serveRouter(rt, http.MethodGet, "/users")
+	head := serveRouter(rt, http.MethodHead, "/users")
+
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Empty(t, head.Body.String())
+	assert.Equal(t, strconv.Itoa(get.Body.Len()), head.Header().Get("Content-Length"))
+	assert.Equal(t, "application/json", head.Header().Get("Content-Type"))
+
+	head = serveRouter(rt, http.MethodHead, "/empty")
+
+	assert.Equal(t, http.StatusNoContent, head.Code)
+	assert.Empty(t, head.Header().Get("Content-Length"))
+
+	get = serveRouter(rt, http.MethodGet, "/error")
+	head = serveRouter(rt, http.MethodHead, "/error")
+
+	assert.Equal(t, http.StatusConflict, head.Code)
+	assert.Empty(t, head.Body.String())
+	assert.Equal(t, strconv.Itoa(get.Body.Len()), head.Header().Get("Content-Length"))
+
+	head = serveRouter(rt, http.MethodHead, "/custom")
+
+	assert.Equal(t, "head", head.Header().Get("X-Custom"))
+}
+
+func TestRouter_AutoHeadStreaming(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /items", func(w http.ResponseWriter, r *http.Request) error {
+		return WriteJSONArray(w, r, http.StatusOK, ItemsFromSeq(slices.Values([]int{1, 2, 3})), WithJSONArrayFlush(1, time.Hour))
+	})
+
+	head := serveRouter(rt, http.MethodHead, "/items")
+
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Empty(t, head.Body.String())
+	assert.Empty(t, head.Header().Get("Content-Length"))
+	assert.True(t, head.Flushed)
+}
+
+func TestRouter_WithoutAutoHead(t *testing.T) {
+	rt := NewRouter(WithoutAutoHead())
+	rt.Handle("GET /users", textHandler("list"))
+
+	rec := serveRouter(rt, http.MethodHead, "/users")
+
+	// The recorder does not discard bodies, unlike a real server
+	assert.Equal(t, "list", rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+}
@@ -0,0 +1,267 @@
+package httpbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultSSEKeepAlive = 15 * time.Second
+
+type SSEEvent struct {
+	ID    string
+	Event string
+	// Data may span several lines, each one being sent as its own data field
+	Data  string
+	Retry time.Duration
+}
+
+var (
+	ErrSSEClosed       = errors.New("SSE stream closed")
+	errInvalidSSEField = errors.New("SSE event ID and name cannot contain line breaks")
+)
+
+type sseConfig struct {
+	keepAlive time.Duration
+	retry     time.Duration
+}
+
+type SSEOption func(*sseConfig)
+
+// WithSSEKeepAlive sets the interval of the comments sent to keep idle
+// connections open through proxies. Zero disables them
+func WithSSEKeepAlive(interval time.Duration) SSEOption {
+	return func(cfg *sseConfig) {
+		cfg.keepAlive = interval
+	}
+}
+
+// WithSSERetry tells clients how long to wait before reconnecting
+func WithSSERetry(retry time.Duration) SSEOption {
+	return func(cfg *sseConfig) {
+		cfg.retry = retry
+	}
+}
+
+// SSEStream writes Server-Sent Events. It is safe for concurrent use
+type SSEStream struct {
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	r           *http.Request
+	lastEventID string
+
+	mu     sync.Mutex
+	closed bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSSEStream sends the headers of an event stream. The stream must be closed
+// before the handler returns
+func NewSSEStream(w http.ResponseWriter, r *http.Request, opts ...SSEOption) (*SSEStream, error) {
+	cfg := &sseConfig{keepAlive: DefaultSSEKeepAlive}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// Checked before committing the status, so that the error can still be
+	// sent as a regular response
+	if !canFlush(w) {
+		return nil, fmt.Errorf("event streams require flushing: %w", http.ErrNotSupported)
+	}
+
+	s := &SSEStream{
+		w:           w,
+		rc:          http.NewResponseController(w),
+		r:           r,
+		lastEventID: r.Header.Get("Last-Event-ID"),
+		stop:        make(chan struct{}),
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// Disables response buffering in nginx
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := s.rc.Flush(); err != nil {
+		return nil, fmt.Errorf("event streams require flushing: %w", err)
+	}
+
+	if cfg.retry > 0 {
+		if err := s.Send(SSEEvent{Retry: cfg.retry}); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.keepAlive > 0 {
+		s.wg.Add(1)
+		go s.keepAlive(cfg.keepAlive)
+	}
+
+	return s, nil
+}
+
+// canFlush reports whether w, or a writer it wraps, supports flushing, the way
+// http.ResponseController looks for it
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case interface{ FlushError() error }, http.Flusher:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// LastEventID returns the ID of the last event received by a reconnecting
+// client, for the stream to resume after it
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+func (s *SSEStream) Send(event SSEEvent) error {
+	if strings.ContainsAny(event.ID, "\r\n\x00") || strings.ContainsAny(event.Event, "\r\n") {
+		return errInvalidSSEField
+	}
+
+	var b strings.Builder
+
+	if event.ID != "" {
+		b.WriteString("id: " + event.ID + "\n")
+	}
+
+	if event.Event != "" {
+		b.WriteString("event: " + event.Event + "\n")
+	}
+
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	if event.Data != "" {
+		data := strings.ReplaceAll(event.Data, "\r\n", "\n")
+		data = strings.ReplaceAll(data, "\r", "\n")
+
+		for line := range strings.SplitSeq(data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// SendJSON sends data encoded as JSON in an event of the given type
+func (s *SSEStream) SendJSON(event string, id string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return s.Send(SSEEvent{ID: id, Event: event, Data: string(encoded)})
+}
+
+// Comment sends a comment line, ignored by clients
+func (s *SSEStream) Comment(text string) error {
+	var b strings.Builder
+
+	for line := range strings.SplitSeq(strings.ReplaceAll(text, "\r", ""), "\n") {
+		b.WriteString(": " + line + "\n")
+	}
+
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+func (s *SSEStream) write(frame string) error {
+	if err := s.r.Context().Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSSEClosed
+	}
+
+	if _, err := s.w.Write([]byte(frame)); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
+
+func (s *SSEStream) keepAlive(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.Comment("keep-alive") != nil {
+				return
+			}
+		case <-s.stop:
+			return
+		case <-s.r.Context().Done():
+			return
+		}
+	}
+}
+
+// Close stops the keep-alive comments. Events can no longer be sent afterwards
+func (s *SSEStream) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// SSEHandler returns a Handler serving an event stream. As the status is sent
+// before fn runs, errors returned by fn are sent to the client as an "error"
+// event holding the JSON error, and the stream ends
+func SSEHandler(fn func(stream *SSEStream, r *http.Request) error, opts ...SSEOption) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		stream, err := NewSSEStream(w, r, opts...)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		err = fn(stream, r)
+		if err == nil || IsClientClosedRequest(r, err) {
+			return err
+		}
+
+		httpErr := requestError(r, err)
+		logError(r, httpErr)
+
+		stream.SendJSON("error", "", httpErr)
+
+		return nil
+	}
+}
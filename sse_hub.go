@@ -0,0 +1,321 @@
+package httpbox
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// SlowConsumerPolicy decides what happens when an event is published to a
+// subscriber whose buffer is full
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerEvict closes the subscription. SSE clients reconnect and
+	// catch up through the replayed events
+	SlowConsumerEvict SlowConsumerPolicy = iota
+	// SlowConsumerDropOldest discards the oldest buffered event
+	SlowConsumerDropOldest
+	// SlowConsumerDropNewest discards the published event
+	SlowConsumerDropNewest
+)
+
+type sseHubConfig struct {
+	buffer     int
+	replay     int
+	slowPolicy SlowConsumerPolicy
+	metrics    *sseHubMetrics
+}
+
+type SSEHubOption func(*sseHubConfig)
+
+// WithSSEHubBuffer sets how many events can be waiting for each subscriber, at
+// least 1
+func WithSSEHubBuffer(size int) SSEHubOption {
+	return func(cfg *sseHubConfig) {
+		cfg.buffer = size
+	}
+}
+
+// WithSSEHubReplay keeps the last size events of each topic, sent again to
+// clients reconnecting with a Last-Event-ID
+func WithSSEHubReplay(size int) SSEHubOption {
+	return func(cfg *sseHubConfig) {
+		cfg.replay = size
+	}
+}
+
+func WithSSEHubSlowConsumerPolicy(policy SlowConsumerPolicy) SSEHubOption {
+	return func(cfg *sseHubConfig) {
+		cfg.slowPolicy = policy
+	}
+}
+
+// WithSSEHubMetrics records subscribers and events in reg, labeled with the
+// hub name
+func WithSSEHubMetrics(reg *Registry, hub string) SSEHubOption {
+	return func(cfg *sseHubConfig) {
+		cfg.metrics = &sseHubMetrics{
+			hub:         hub,
+			subscribers: reg.Gauge("sse_subscribers", "Number of SSE hub subscribers.", "hub"),
+			published:   reg.Counter("sse_events_published_total", "Total number of events published to an SSE hub.", "hub"),
+			dropped:     reg.Counter("sse_events_dropped_total", "Total number of events dropped for slow SSE subscribers.", "hub"),
+			evicted:     reg.Counter("sse_subscribers_evicted_total", "Total number of slow SSE subscribers evicted.", "hub"),
+		}
+	}
+}
+
+type sseHubMetrics struct {
+	hub         string
+	subscribers *GaugeVec
+	published   *CounterVec
+	dropped     *CounterVec
+	evicted     *CounterVec
+}
+
+// SSEHub broadcasts events published to a topic to all its subscribers
+type SSEHub struct {
+	cfg *sseHubConfig
+
+	mu     sync.Mutex
+	topics map[string]*sseTopic
+	nextID uint64
+	closed bool
+}
+
+type sseTopic struct {
+	subscribers map[*SSESubscription]struct{}
+	history     []SSEEvent
+}
+
+func NewSSEHub(opts ...SSEHubOption) *SSEHub {
+	cfg := &sseHubConfig{buffer: 16}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	cfg.buffer = max(cfg.buffer, 1)
+
+	return &SSEHub{cfg: cfg, topics: make(map[string]*sseTopic)}
+}
+
+type SSESubscription struct {
+	hub    *SSEHub
+	topic  string
+	events chan SSEEvent
+	closed bool
+}
+
+// Events returns the channel of published events. It is closed when the
+// subscription is closed or evicted
+func (s *SSESubscription) Events() <-chan SSEEvent {
+	return s.events
+}
+
+func (s *SSESubscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.removeLocked(s)
+}
+
+func (h *SSEHub) topicLocked(name string) *sseTopic {
+	topic, ok := h.topics[name]
+	if !ok {
+		topic = &sseTopic{subscribers: make(map[*SSESubscription]struct{})}
+		h.topics[name] = topic
+	}
+
+	return topic
+}
+
+// Subscribe registers a subscriber to topic. When lastEventID is set, the
+// kept events published after it are delivered first, or all of them if it
+// is too old to be found
+func (h *SSEHub) Subscribe(topic string, lastEventID string) *SSESubscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t := h.topicLocked(topic)
+
+	var replay []SSEEvent
+	if lastEventID != "" {
+		replay = t.history
+		for i, event := range t.history {
+			if event.ID == lastEventID {
+				replay = t.history[i+1:]
+				break
+			}
+		}
+	}
+
+	sub := &SSESubscription{
+		hub:    h,
+		topic:  topic,
+		events: make(chan SSEEvent, h.cfg.buffer+len(replay)),
+	}
+
+	for _, event := range replay {
+		sub.events <- event
+	}
+
+	if h.closed {
+		sub.closed = true
+		close(sub.events)
+		return sub
+	}
+
+	t.subscribers[sub] = struct{}{}
+
+	if m := h.cfg.metrics; m != nil {
+		m.subscribers.Inc(m.hub)
+	}
+
+	return sub
+}
+
+// Publish sends event to the subscribers of topic and returns it. Events
+// without ID are given one, so that clients can resume after them
+func (h *SSEHub) Publish(topic string, event SSEEvent) SSEEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return event
+	}
+
+	if event.ID == "" {
+		h.nextID++
+		event.ID = strconv.FormatUint(h.nextID, 10)
+	}
+
+	t := h.topicLocked(topic)
+
+	if h.cfg.replay > 0 {
+		if len(t.history) >= h.cfg.replay {
+			t.history = append(t.history[:0], t.history[len(t.history)-h.cfg.replay+1:]...)
+		}
+		t.history = append(t.history, event)
+	}
+
+	m := h.cfg.metrics
+	if m != nil {
+		m.published.Inc(m.hub)
+	}
+
+	for sub := range t.subscribers {
+		select {
+		case sub.events <- event:
+			continue
+		default:
+		}
+
+		switch h.cfg.slowPolicy {
+		case SlowConsumerEvict:
+			h.removeLocked(sub)
+			if m != nil {
+				m.evicted.Inc(m.hub)
+			}
+
+		case SlowConsumerDropOldest:
+			// The subscriber may have read an event meanwhile, leaving room. The
+			// send must not block while holding the lock, so the event itself is
+			// dropped when there is still no room
+			select {
+			case <-sub.events:
+			default:
+			}
+			select {
+			case sub.events <- event:
+			default:
+			}
+			if m != nil {
+				m.dropped.Inc(m.hub)
+			}
+
+		case SlowConsumerDropNewest:
+			if m != nil {
+				m.dropped.Inc(m.hub)
+			}
+		}
+	}
+
+	if len(t.subscribers) == 0 && len(t.history) == 0 {
+		delete(h.topics, topic)
+	}
+
+	return event
+}
+
+func (h *SSEHub) removeLocked(sub *SSESubscription) {
+	if sub.closed {
+		return
+	}
+
+	sub.closed = true
+	close(sub.events)
+
+	t := h.topics[sub.topic]
+	delete(t.subscribers, sub)
+
+	if len(t.subscribers) == 0 && len(t.history) == 0 {
+		delete(h.topics, sub.topic)
+	}
+
+	if m := h.cfg.metrics; m != nil {
+		m.subscribers.Dec(m.hub)
+	}
+}
+
+// Subscribers returns the number of subscribers of topic
+func (h *SSEHub) Subscribers(topic string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if t, ok := h.topics[topic]; ok {
+		return len(t.subscribers)
+	}
+
+	return 0
+}
+
+// Close ends all subscriptions, typically on server shutdown
+func (h *SSEHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+
+	for _, t := range h.topics {
+		for sub := range t.subscribers {
+			h.removeLocked(sub)
+		}
+	}
+}
+
+// Handler streams the events published to the topic returned by topic. Evicted
+// clients have their stream ended, and resume through Last-Event-ID when they
+// reconnect
+func (h *SSEHub) Handler(topic func(r *http.Request) string, opts ...SSEOption) Handler {
+	return SSEHandler(func(stream *SSEStream, r *http.Request) error {
+		sub := h.Subscribe(topic(r), stream.LastEventID())
+		defer sub.Close()
+
+		for {
+			select {
+			case event, ok := <-sub.Events():
+				if !ok {
+					return nil
+				}
+
+				if err := stream.Send(event); err != nil {
+					return err
+				}
+
+			case <-r.Context().Done():
+				return r.Context().Err()
+			}
+		}
+	}, opts...)
+}
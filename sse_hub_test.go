@@ -0,0 +1,200 @@
+package httpbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receiveEvents(sub *SSESubscription) []string {
+	var data []string
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return data
+			}
+			data = append(data, event.Data)
+		default:
+			return data
+		}
+	}
+}
+
+func TestSSEHub_PublishSubscribe(t *testing.T) {
+	hub := NewSSEHub()
+
+	news := hub.Subscribe("news", "")
+	other := hub.Subscribe("sports", "")
+
+	first := hub.Publish("news", SSEEvent{Data: "first"})
+	second := hub.Publish("news", SSEEvent{ID: "custom", Data: "second"})
+
+	assert.Equal(t, "1", first.ID)
+	assert.Equal(t, "custom", second.ID)
+	assert.Equal(t, []string{"first", "second"}, receiveEvents(news))
+	assert.Empty(t, receiveEvents(other))
+	assert.Equal(t, 1, hub.Subscribers("news"))
+
+	news.Close()
+	news.Close()
+
+	assert.Equal(t, 0, hub.Subscribers("news"))
+	_, ok := <-news.Events()
+	assert.False(t, ok)
+}
+
+func TestSSEHub_Replay(t *testing.T) {
+	hub := NewSSEHub(WithSSEHubReplay(3))
+
+	for _, data := range []string{"a", "b", "c", "d"} {
+		hub.Publish("news", SSEEvent{Data: data})
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		expected    []string
+	}{
+		{"new client", "", nil},
+		{"known ID", "2", []string{"c", "d"}},
+		{"up to date", "4", nil},
+		{"expired ID", "1", []string{"b", "c", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := hub.Subscribe("news", tt.lastEventID)
+			defer sub.Close()
+
+			assert.Equal(t, tt.expected, receiveEvents(sub))
+		})
+	}
+}
+
+func TestSSEHub_SlowConsumer(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   SlowConsumerPolicy
+		expected []string
+		evicted  bool
+	}{
+		{"evict", SlowConsumerEvict, []string{"a", "b"}, true},
+		{"drop oldest", SlowConsumerDropOldest, []string{"b", "c"}, false},
+		{"drop newest", SlowConsumerDropNewest, []string{"a", "b"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewRegistry()
+			hub := NewSSEHub(
+				WithSSEHubBuffer(2),
+				WithSSEHubSlowConsumerPolicy(tt.policy),
+				WithSSEHubMetrics(reg, "test"),
+			)
+
+			sub := hub.Subscribe("news", "")
+
+			for _, data := range []string{"a", "b", "c"} {
+				hub.Publish("news", SSEEvent{Data: data})
+			}
+
+			assert.Equal(t, tt.expected, receiveEvents(sub))
+
+			if tt.evicted {
+				assert.Equal(t, 0, hub.Subscribers("news"))
+				assert.Contains(t, renderMetrics(t, reg), `sse_subscribers_evicted_total{hub="test"} 1`)
+			} else {
+				assert.Equal(t, 1, hub.Subscribers("news"))
+				assert.Contains(t, renderMetrics(t, reg), `sse_events_dropped_total{hub="test"} 1`)
+			}
+		})
+	}
+}
+
+func TestSSEHub_UnbufferedDropOldest(t *testing.T) {
+	// A buffer below 1 is raised to 1, and publishing never blocks
+	hub := NewSSEHub(WithSSEHubBuffer(0), WithSSEHubSlowConsumerPolicy(SlowConsumerDropOldest))
+	sub := hub.Subscribe("news", "")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, data := range []string{"a", "b", "c"} {
+			hub.Publish("news", SSEEvent{Data: data})
+		}
+		hub.Close()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked")
+	}
+
+	assert.Equal(t, []string{"c"}, receiveEvents(sub))
+}
+
+func TestSSEHub_Metrics(t *testing.T) {
+	reg := NewRegistry()
+	hub := NewSSEHub(WithSSEHubMetrics(reg, "test"))
+
+	first := hub.Subscribe("news", "")
+	hub.Subscribe("news", "")
+	hub.Publish("news", SSEEvent{Data: "a"})
+	first.Close()
+
+	metrics := renderMetrics(t, reg)
+
+	assert.Contains(t, metrics, `sse_subscribers{hub="test"} 1`)
+	assert.Contains(t, metrics, `sse_events_published_total{hub="test"} 1`)
+}
+
+func TestSSEHub_Close(t *testing.T) {
+	hub := NewSSEHub()
+	sub := hub.Subscribe("news", "")
+
+	hub.Close()
+
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+
+	_, ok = <-hub.Subscribe("news", "").Events()
+	assert.False(t, ok)
+}
+
+func TestSSEHub_Handler(t *testing.T) {
+	hub := NewSSEHub(WithSSEHubReplay(10))
+	hub.Publish("news", SSEEvent{Data: "missed"})
+	hub.Publish("news", SSEEvent{Data: "seen"})
+
+	h := hub.Handler(func(r *http.Request) string {
+		return r.URL.Query().Get("topic")
+	}, WithSSEKeepAlive(0))
+
+	req := httptest.NewRequest(http.MethodGet, "/events?topic=news", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return hub.Subscribers("news") == 1 }, time.Second, time.Millisecond)
+
+	hub.Publish("news", SSEEvent{Event: "update", Data: "live"})
+
+	// Buffered events are still delivered before the stream ends
+	hub.Close()
+	<-done
+
+	assert.Equal(t, "id: 2\ndata: seen\n\nid: 3\nevent: update\ndata: live\n\n", rec.Body.String())
+	assert.Equal(t, 0, hub.Subscribers("news"))
+}
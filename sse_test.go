@@ -0,0 +1,185 @@
+package httpbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveSSE(h Handler, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestSSEHandler_Events(t *testing.T) {
+	h := SSEHandler(func(stream *SSEStream, r *http.Request) error {
+		if err := stream.Send(SSEEvent{ID: "1", Event: "update", Data: "first line\nsecond line\r\nthird line"}); err != nil {
+			return err
+		}
+
+		if err := stream.SendJSON("user", "2", map[string]string{"name": "John"}); err != nil {
+			return err
+		}
+
+		return stream.Comment("done")
+	}, WithSSERetry(3*time.Second), WithSSEKeepAlive(0))
+
+	rec := serveSSE(h, nil)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	assert.True(t, rec.Flushed)
+
+	expected := "retry: 3000\n\n" +
+		"id: 1\nevent: update\ndata: first line\ndata: second line\ndata: third line\n\n" +
+		"id: 2\nevent: user\ndata: {\"name\":\"John\"}\n\n" +
+		": done\n\n"
+	assert.Equal(t, expected, rec.Body.String())
+}
+
+func TestSSEStream_InvalidFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		event SSEEvent
+	}{
+		{"ID with newline", SSEEvent{ID: "1\n2", Data: "x"}},
+		{"ID with NUL", SSEEvent{ID: "1\x00", Data: "x"}},
+		{"event with newline", SSEEvent{Event: "a\rb", Data: "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sendErr error
+			h := SSEHandler(func(stream *SSEStream, r *http.Request) error {
+				sendErr = stream.Send(tt.event)
+				return nil
+			}, WithSSEKeepAlive(0))
+
+			rec := serveSSE(h, nil)
+
+			assert.ErrorIs(t, sendErr, errInvalidSSEField)
+			assert.Empty(t, rec.Body.String())
+		})
+	}
+}
+
+func TestSSEStream_LastEventID(t *testing.T) {
+	var lastEventID string
+	h := SSEHandler(func(stream *SSEStream, r *http.Request) error {
+		lastEventID = stream.LastEventID()
+		return nil
+	})
+
+	serveSSE(h, map[string]string{"Last-Event-ID": "42"})
+
+	assert.Equal(t, "42", lastEventID)
+}
+
+func TestSSEStream_KeepAlive(t *testing.T) {
+	h := SSEHandler(func(stream *SSEStream, r *http.Request) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}, WithSSEKeepAlive(5*time.Millisecond))
+
+	rec := serveSSE(h, nil)
+
+	assert.Contains(t, rec.Body.String(), ": keep-alive\n\n")
+}
+
+func TestSSEStream_Closed(t *testing.T) {
+	var stream *SSEStream
+	h := SSEHandler(func(s *SSEStream, r *http.Request) error {
+		stream = s
+		return nil
+	})
+
+	serveSSE(h, nil)
+
+	assert.ErrorIs(t, stream.Send(SSEEvent{Data: "late"}), ErrSSEClosed)
+}
+
+func TestSSEHandler_Error(t *testing.T) {
+	h := SSEHandler(func(stream *SSEStream, r *http.Request) error {
+		stream.Send(SSEEvent{Data: "partial"})
+		return NewError(http.StatusConflict, "conflict")
+	}, WithSSEKeepAlive(0))
+
+	rec := serveSSE(h, nil)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "data: partial\n\nevent: error\ndata: {\"code\":409,\"message\":\"conflict\"}\n\n", rec.Body.String())
+}
+
+func TestSSEHandler_ClientDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sent := 0
+	h := SSEHandler(func(stream *SSEStream, r *http.Request) error {
+		for {
+			if err := stream.Send(SSEEvent{Data: "tick"}); err != nil {
+				return err
+			}
+
+			sent++
+			if sent == 3 {
+				cancel()
+			}
+		}
+	}, WithSSEKeepAlive(0))
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	require.Equal(t, 3, sent)
+	assert.Equal(t, 3, strings.Count(rec.Body.String(), "data: tick"))
+	assert.NotContains(t, rec.Body.String(), "error")
+}
+
+// noFlushWriter hides the Flush method of the wrapped writer, counting the
+// statuses sent
+type noFlushWriter struct {
+	http.ResponseWriter
+	writeHeaders int
+}
+
+func (w *noFlushWriter) WriteHeader(statusCode int) {
+	w.writeHeaders++
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func TestNewSSEStream_FlushNotSupported(t *testing.T) {
+	captureLogs(t)
+
+	called := false
+	h := SSEHandler(func(stream *SSEStream, r *http.Request) error {
+		called = true
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	w := &noFlushWriter{ResponseWriter: rec}
+
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	assert.False(t, called)
+	assert.Equal(t, 1, w.writeHeaders)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":500,"message":"Unexpected error occurred"}`, rec.Body.String())
+}
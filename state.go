@@ -0,0 +1,61 @@
+package httpbox
+
+import (
+	"context"
+	"net/http"
+)
+
+type requestStateKey struct{}
+
+// requestState holds data produced by middlewares that must remain visible to
+// code running outside of them, such as handleError or an outer access log.
+// A context value alone is not enough because the outer layers only see the
+// request they passed down, not the one derived by inner middlewares
+type requestState struct {
+	requestID        string
+	requestIDInError bool
+	spanContext      SpanContext
+	pattern          string
+	principal        Principal
+	claims           any
+	// clientClosed is set by handleError when the client went away, since the
+	// error never reaches middlewares wrapping a mux
+	clientClosed bool
+	// serving is set by the outermost Handler.ServeHTTP, which aborts the
+	// response once aborted is set by an inner one
+	serving bool
+	aborted bool
+}
+
+func requestStateFrom(ctx context.Context) *requestState {
+	state, _ := ctx.Value(requestStateKey{}).(*requestState)
+	return state
+}
+
+// withRequestState returns a request carrying a requestState, reusing the one
+// already attached to r if any
+func withRequestState(r *http.Request) (*http.Request, *requestState) {
+	if state := requestStateFrom(r.Context()); state != nil {
+		return r, state
+	}
+
+	state := &requestState{}
+	ctx := context.WithValue(r.Context(), requestStateKey{}, state)
+
+	return r.WithContext(ctx), state
+}
+
+// routePattern returns the ServeMux pattern that matched the request. Outer
+// middlewares may hold a request that never went through the mux, in which case
+// the pattern recorded by the inner Handler is used
+func routePattern(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+
+	if state := requestStateFrom(r.Context()); state != nil {
+		return state.pattern
+	}
+
+	return ""
+}
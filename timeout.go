@@ -0,0 +1,287 @@
+package httpbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type timeoutConfig struct {
+	statusCode  int
+	overrides   map[string]time.Duration
+	gracePeriod time.Duration
+}
+
+type TimeoutOption func(*timeoutConfig)
+
+// WithTimeoutStatus sets the status of timed out requests, usually 503 Service
+// Unavailable (default) or 504 Gateway Timeout
+func WithTimeoutStatus(statusCode int) TimeoutOption {
+	return func(cfg *timeoutConfig) {
+		cfg.statusCode = statusCode
+	}
+}
+
+// WithRouteTimeout overrides the timeout of the route registered with pattern
+func WithRouteTimeout(pattern string, timeout time.Duration) TimeoutOption {
+	return func(cfg *timeoutConfig) {
+		cfg.overrides[pattern] = timeout
+	}
+}
+
+// WithTimeoutGracePeriod sets how long a handler may keep running after its
+// deadline before being logged as ignoring cancellation
+func WithTimeoutGracePeriod(gracePeriod time.Duration) TimeoutOption {
+	return func(cfg *timeoutConfig) {
+		cfg.gracePeriod = gracePeriod
+	}
+}
+
+// TimeoutMiddleware cancels the request context after timeout. If the handler
+// has not started writing the response by then, the request fails with a
+// timeout error and anything the handler writes afterwards is discarded.
+//
+// The handler runs in its own goroutine so the response can be sent without
+// waiting for handlers that ignore the context. Unlike http.TimeoutHandler,
+// the response is not buffered: writes go straight to the client once the
+// status is sent, which also keeps streaming responses working
+func TimeoutMiddleware(timeout time.Duration, opts ...TimeoutOption) Middleware {
+	cfg := &timeoutConfig{
+		statusCode:  http.StatusServiceUnavailable,
+		overrides:   make(map[string]time.Duration),
+		gracePeriod: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			routeTimeout := timeout
+			if override, ok := cfg.overrides[routePattern(r)]; ok {
+				routeTimeout = override
+			}
+
+			r, state := withRequestState(r)
+
+			// The handler gets its own copy of the request state, merged back
+			// once it returns, since it may keep writing it after the deadline
+			// while outer middlewares read it
+			handlerState := *state
+
+			ctx, cancel := context.WithTimeout(r.Context(), routeTimeout)
+			defer cancel()
+
+			// ServeMux also sets the pattern of the request it receives
+			hr := r.WithContext(context.WithValue(ctx, requestStateKey{}, &handlerState))
+			tw := &timeoutWriter{w: w, header: w.Header().Clone()}
+
+			done := make(chan error, 1)
+			panicked := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+
+				done <- h(tw, hr)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+
+			case err := <-done:
+				*state = handlerState
+				committed := tw.finish()
+
+				if !committed && errors.Is(err, context.DeadlineExceeded) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return cfg.newError()
+				}
+
+				return err
+
+			case <-ctx.Done():
+				if !tw.timeout() {
+					// The response is already being written, so the handler is
+					// left to finish it, observing the canceled context
+					select {
+					case p := <-panicked:
+						panic(p)
+					case err := <-done:
+						*state = handlerState
+						return err
+					}
+				}
+
+				// The attributes are read now, the handler state being off limits
+				go cfg.watch(timeoutLogAttrs(r, state), done, panicked)
+
+				// Otherwise the parent context was canceled, typically because
+				// the client disconnected
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return cfg.newError()
+				}
+
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (cfg *timeoutConfig) newError() *Error {
+	return NewError(cfg.statusCode, "request timed out", WithInternalError(context.DeadlineExceeded))
+}
+
+func timeoutLogAttrs(r *http.Request, state *requestState) []any {
+	ctx := context.WithValue(r.Context(), requestStateKey{}, state)
+
+	pattern := r.Pattern
+	if pattern == "" {
+		pattern = state.pattern
+	}
+
+	return append(logAttrs(ctx),
+		"method", r.Method,
+		"url", r.URL.String(),
+		"route", pattern,
+	)
+}
+
+// watch logs handlers still running well after their context was canceled,
+// as they keep holding resources the client is no longer waiting for
+func (cfg *timeoutConfig) watch(attrs []any, done <-chan error, panicked <-chan any) {
+	timer := time.NewTimer(cfg.gracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return
+	case p := <-panicked:
+		slog.Error("Handler panicked after timeout", append(attrs, "panic", p)...)
+		return
+	case <-timer.C:
+	}
+
+	start := time.Now()
+
+	slog.Warn("Handler ignored context cancellation", append(attrs, "grace_period", cfg.gracePeriod)...)
+
+	select {
+	case <-done:
+	case <-panicked:
+	}
+
+	slog.Warn("Handler finished after ignoring context cancellation",
+		append(attrs, "overrun", cfg.gracePeriod+time.Since(start))...)
+}
+
+// timeoutWriter gives the handler its own header map, copied when the status is
+// sent, so that the error response written on timeout never races with it
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.writeHeaderLocked(statusCode)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(statusCode int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+
+	if statusCode >= 200 {
+		tw.wroteHeader = true
+	}
+
+	tw.copyHeaderLocked()
+	tw.w.WriteHeader(statusCode)
+}
+
+func (tw *timeoutWriter) copyHeaderLocked() {
+	dst := tw.w.Header()
+	clear(dst)
+	maps.Copy(dst, tw.header)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	tw.writeHeaderLocked(http.StatusOK)
+
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) FlushError() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+
+	tw.writeHeaderLocked(http.StatusOK)
+
+	return http.NewResponseController(tw.w).Flush()
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.FlushError()
+}
+
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// timeout marks the response as timed out unless the handler already sent the
+// status, reporting whether it did so
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.wroteHeader {
+		return false
+	}
+
+	tw.timedOut = true
+	return true
+}
+
+// finish is called once the handler returned, reporting whether the response
+// was committed. Headers set by a handler returning an error are kept so that
+// the error response includes them
+func (tw *timeoutWriter) finish() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if !tw.wroteHeader {
+		tw.copyHeaderLocked()
+	}
+
+	return tw.wroteHeader
+}
@@ -0,0 +1,205 @@
+package httpbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutMiddleware_Completes(t *testing.T) {
+	h := textHandler("ok").WithMiddlewares(TimeoutMiddleware(time.Second))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+}
+
+func TestTimeoutMiddleware_HandlerObservesDeadline(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		<-r.Context().Done()
+		return r.Context().Err()
+	}).WithMiddlewares(TimeoutMiddleware(10 * time.Millisecond))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"code":503,"message":"request timed out"}`, rec.Body.String())
+}
+
+func TestTimeoutMiddleware_HandlerIgnoresDeadline(t *testing.T) {
+	logs := captureLogs(t)
+
+	release := make(chan struct{})
+	finished := make(chan error, 1)
+
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		<-release
+		w.Header().Set("X-Late", "true")
+		_, err := w.Write([]byte("late"))
+		finished <- err
+		return nil
+	}).WithMiddlewares(TimeoutMiddleware(10*time.Millisecond,
+		WithTimeoutStatus(http.StatusGatewayTimeout),
+		WithTimeoutGracePeriod(10*time.Millisecond),
+	))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.ErrorIs(t, <-finished, http.ErrHandlerTimeout)
+	assert.Empty(t, rec.Header().Get("X-Late"))
+	assert.NotContains(t, rec.Body.String(), "late")
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "Handler finished after ignoring context cancellation")
+	}, time.Second, 5*time.Millisecond)
+	assert.Contains(t, logs.String(), "Handler ignored context cancellation")
+}
+
+func TestTimeoutMiddleware_CommittedResponse(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		<-r.Context().Done()
+		return nil
+	}).WithMiddlewares(TimeoutMiddleware(10 * time.Millisecond))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String())
+}
+
+func TestTimeoutMiddleware_KeepsHeadersOfErrors(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return NewError(http.StatusUnauthorized, "unauthorized")
+	}).WithMiddlewares(TimeoutMiddleware(time.Second))
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestTimeoutMiddleware_RouteOverride(t *testing.T) {
+	deadlines := make(map[string]time.Duration)
+
+	rt := NewRouter()
+	rt.Use(TimeoutMiddleware(time.Second, WithRouteTimeout("GET /slow", time.Minute)))
+
+	for _, pattern := range []string{"GET /fast", "GET /slow"} {
+		rt.Handle(pattern, func(w http.ResponseWriter, r *http.Request) error {
+			deadline, ok := r.Context().Deadline()
+			require.True(t, ok)
+			deadlines[r.Pattern] = time.Until(deadline)
+			return nil
+		})
+	}
+
+	serveRouter(rt, http.MethodGet, "/fast")
+	serveRouter(rt, http.MethodGet, "/slow")
+
+	assert.LessOrEqual(t, deadlines["GET /fast"], time.Second)
+	assert.Greater(t, deadlines["GET /slow"], time.Second)
+}
+
+func TestTimeoutMiddleware_Panic(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		panic("boom")
+	}).WithMiddlewares(TimeoutMiddleware(time.Second))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+	})
+}
+
+func TestTimeoutMiddleware_ParentCanceled(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		<-r.Context().Done()
+		return r.Context().Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := TimeoutMiddleware(time.Second)(h)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(ctx))
+
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestTimeoutMiddleware_RouterStateRace(t *testing.T) {
+	reg := NewRegistry()
+	release := make(chan struct{})
+	finished := make(chan struct{})
+
+	rt := NewRouter()
+	rt.Handle("GET /slow", func(w http.ResponseWriter, r *http.Request) error {
+		defer close(finished)
+		<-release
+		return nil
+	})
+
+	h := AdaptHandler(rt).WithMiddlewares(
+		MetricsMiddleware(reg),
+		TimeoutMiddleware(10*time.Millisecond, WithTimeoutGracePeriod(time.Hour)),
+	)
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, renderMetrics(t, reg), `status="503"`)
+
+	// The handler returns after the response was sent, going through the
+	// Handler.ServeHTTP of the route while the request state is read above
+	close(release)
+	<-finished
+}
+
+func TestTimeoutMiddleware_MergesRequestState(t *testing.T) {
+	reg := NewRegistry()
+
+	rt := NewRouter()
+	rt.Handle("GET /users/{id}", textHandler("user"))
+
+	h := AdaptHandler(rt).WithMiddlewares(MetricsMiddleware(reg), TimeoutMiddleware(time.Second))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	assert.Contains(t, renderMetrics(t, reg), `http_requests_total{method="GET",route="GET /users/{id}",status="200"} 1`)
+}
+
+func TestTimeoutMiddleware_ResponseController(t *testing.T) {
+	srv := httptest.NewServer(Handler(func(w http.ResponseWriter, r *http.Request) error {
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			return err
+		}
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte("ok"))
+	}).WithMiddlewares(TimeoutMiddleware(time.Second)))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
@@ -0,0 +1,388 @@
+package httpbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TraceparentHeader   = "traceparent"
+	TracestateHeader    = "tracestate"
+	TraceresponseHeader = "traceresponse"
+)
+
+const maxTracestateMembers = 32
+
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+type TraceFlags byte
+
+const TraceFlagsSampled TraceFlags = 0x01
+
+type SpanContext struct {
+	TraceID      TraceID
+	SpanID       SpanID
+	ParentSpanID SpanID
+	Flags        TraceFlags
+	TraceState   string
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&TraceFlagsSampled != 0
+}
+
+// Traceparent formats the span context as a version 00 traceparent header value
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, byte(sc.Flags))
+}
+
+var (
+	errInvalidTraceparent = errors.New("invalid traceparent")
+	errInvalidTracestate  = errors.New("invalid tracestate")
+)
+
+// ParseTraceparent parses a traceparent header as defined by W3C Trace Context.
+// The returned SpanContext has the parent span ID stored in SpanID
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	// Future versions may append fields, so only the length of version 00 is exact
+	if len(value) < 55 || (len(value) > 55 && value[55] != '-') {
+		return sc, errInvalidTraceparent
+	}
+
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, errInvalidTraceparent
+	}
+
+	version, ok := decodeLowerHex(value[0:2])
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(value) != 55) {
+		return sc, errInvalidTraceparent
+	}
+
+	traceID, ok := decodeLowerHex(value[3:35])
+	if !ok {
+		return sc, errInvalidTraceparent
+	}
+
+	spanID, ok := decodeLowerHex(value[36:52])
+	if !ok {
+		return sc, errInvalidTraceparent
+	}
+
+	flags, ok := decodeLowerHex(value[53:55])
+	if !ok {
+		return sc, errInvalidTraceparent
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = TraceFlags(flags[0])
+
+	if !sc.IsValid() {
+		return SpanContext{}, errInvalidTraceparent
+	}
+
+	return sc, nil
+}
+
+func decodeLowerHex(s string) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return nil, false
+		}
+	}
+
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// ParseTracestate validates a tracestate header and returns it with empty list
+// members and optional whitespace removed
+func ParseTracestate(value string) (string, error) {
+	var members []string
+	seen := make(map[string]bool)
+
+	for _, member := range strings.Split(value, ",") {
+		member = strings.Trim(member, " \t")
+		if member == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(member, "=")
+		if !ok || !isValidTracestateKey(key) || !isValidTracestateValue(val) || seen[key] {
+			return "", errInvalidTracestate
+		}
+
+		seen[key] = true
+		members = append(members, member)
+	}
+
+	if len(members) > maxTracestateMembers {
+		return "", errInvalidTracestate
+	}
+
+	return strings.Join(members, ","), nil
+}
+
+func isValidTracestateKey(key string) bool {
+	tenant, system, multiTenant := strings.Cut(key, "@")
+
+	if !multiTenant {
+		return len(key) <= 256 && isTracestateKeyPart(key, true)
+	}
+
+	return len(tenant) <= 241 && len(system) <= 14 &&
+		isTracestateKeyPart(tenant, false) && isTracestateKeyPart(system, true)
+}
+
+func isTracestateKeyPart(s string, letterFirst bool) bool {
+	if s == "" {
+		return false
+	}
+
+	if letterFirst && (s[0] < 'a' || s[0] > 'z') {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '-' || c == '*' || c == '/' {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+func isValidTracestateValue(val string) bool {
+	if val == "" || len(val) > 256 || val[len(val)-1] == ' ' {
+		return false
+	}
+
+	for i := 0; i < len(val); i++ {
+		if val[i] < 0x20 || val[i] > 0x7e || val[i] == ',' || val[i] == '=' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	state := requestStateFrom(ctx)
+	if state == nil || !state.spanContext.IsValid() {
+		return SpanContext{}, false
+	}
+
+	return state.spanContext, true
+}
+
+type Span interface {
+	// SetStatus receives the HTTP status code of the response, which is the
+	// code of the *Error returned by the handler when there is one
+	SetStatus(code int)
+	End()
+}
+
+type Tracer interface {
+	StartSpan(r *http.Request, sc SpanContext) Span
+}
+
+type NoopTracer struct{}
+
+func (NoopTracer) StartSpan(r *http.Request, sc SpanContext) Span {
+	return noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetStatus(code int) {}
+
+func (noopSpan) End() {}
+
+type RecordedSpan struct {
+	Name        string
+	SpanContext SpanContext
+	Status      int
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+// RecordingTracer keeps every ended span in memory. It is intended for tests
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []RecordedSpan
+}
+
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+func (t *RecordingTracer) StartSpan(r *http.Request, sc SpanContext) Span {
+	return &recordingSpan{
+		tracer: t,
+		span: RecordedSpan{
+			Name:        r.Method + " " + r.URL.Path,
+			SpanContext: sc,
+			StartTime:   time.Now(),
+		},
+	}
+}
+
+func (t *RecordingTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spans := make([]RecordedSpan, len(t.spans))
+	copy(spans, t.spans)
+
+	return spans
+}
+
+func (t *RecordingTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = nil
+}
+
+type recordingSpan struct {
+	tracer *RecordingTracer
+	span   RecordedSpan
+	once   sync.Once
+}
+
+func (s *recordingSpan) SetStatus(code int) {
+	s.span.Status = code
+}
+
+func (s *recordingSpan) End() {
+	s.once.Do(func() {
+		s.span.EndTime = time.Now()
+
+		s.tracer.mu.Lock()
+		s.tracer.spans = append(s.tracer.spans, s.span)
+		s.tracer.mu.Unlock()
+	})
+}
+
+type traceConfig struct {
+	tracer         Tracer
+	responseHeader string
+}
+
+type TraceOption func(*traceConfig)
+
+func WithTracer(tracer Tracer) TraceOption {
+	return func(cfg *traceConfig) {
+		cfg.tracer = tracer
+	}
+}
+
+// WithTraceResponseHeader changes the header used to send the span context
+// back to the client. An empty name disables it
+func WithTraceResponseHeader(header string) TraceOption {
+	return func(cfg *traceConfig) {
+		cfg.responseHeader = header
+	}
+}
+
+func TraceMiddleware(opts ...TraceOption) Middleware {
+	cfg := &traceConfig{
+		tracer:         NoopTracer{},
+		responseHeader: TraceresponseHeader,
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(h Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			sc := SpanContext{
+				TraceID: newTraceID(),
+				SpanID:  newSpanID(),
+				Flags:   TraceFlagsSampled,
+			}
+
+			// An invalid traceparent restarts the trace, and tracestate is only
+			// meaningful alongside a valid traceparent
+			if parent, err := ParseTraceparent(r.Header.Get(TraceparentHeader)); err == nil {
+				sc.TraceID = parent.TraceID
+				sc.ParentSpanID = parent.SpanID
+				sc.Flags = parent.Flags
+
+				if state, err := ParseTracestate(strings.Join(r.Header.Values(TracestateHeader), ",")); err == nil {
+					sc.TraceState = state
+				}
+			}
+
+			r, state := withRequestState(r)
+			state.spanContext = sc
+
+			if cfg.responseHeader != "" {
+				w.Header().Set(cfg.responseHeader, sc.Traceparent())
+			}
+
+			span := cfg.tracer.StartSpan(r, sc)
+			defer span.End()
+
+			arw := newAccessResponseWriter(w)
+
+			err := h(arw, r)
+
+			span.SetStatus(responseStatus(arw, r, err))
+
+			return err
+		}
+	}
+}
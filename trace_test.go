@@ -0,0 +1,182 @@
+package httpbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent_Valid(t *testing.T) {
+	sc, err := ParseTraceparent(testTraceparent)
+
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled())
+	assert.Equal(t, testTraceparent, sc.Traceparent())
+}
+
+func TestParseTraceparent_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"uppercase hex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{"zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{"forbidden version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"version 00 with extra data", testTraceparent + "-extra"},
+		{"wrong separator", "00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"short trace ID", "00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTraceparent(tt.value)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseTraceparent_FutureVersion(t *testing.T) {
+	sc, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future")
+
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+}
+
+func TestParseTracestate(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		valid    bool
+	}{
+		{"single member", "congo=t61rcWkgMzE", "congo=t61rcWkgMzE", true},
+		{"multiple members with whitespace", "rojo=00f067aa0ba902b7 , congo=t61rcWkgMzE", "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", true},
+		{"empty members", "rojo=1,,congo=2", "rojo=1,congo=2", true},
+		{"multi tenant key", "fw529a3039@dt=abc", "fw529a3039@dt=abc", true},
+		{"uppercase key", "Rojo=1", "", false},
+		{"missing value", "rojo=", "", false},
+		{"duplicate key", "rojo=1,rojo=2", "", false},
+		{"value with equals", "rojo=a=b", "", false},
+		{"too many members", manyTracestateMembers(33), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseTracestate(tt.value)
+
+			if tt.valid {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func manyTracestateMembers(n int) string {
+	members := make([]string, n)
+	for i := range members {
+		members[i] = "k" + strings.Repeat("a", i) + "=v"
+	}
+	return strings.Join(members, ",")
+}
+
+func TestTraceMiddleware_ContinuesTrace(t *testing.T) {
+	tracer := NewRecordingTracer()
+
+	var seen SpanContext
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		seen, _ = SpanContextFromContext(r.Context())
+		return nil
+	}).WithMiddlewares(TraceMiddleware(WithTracer(tracer)))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	req.Header.Set(TracestateHeader, "congo=t61rcWkgMzE")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", seen.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", seen.ParentSpanID.String())
+	assert.NotEqual(t, seen.ParentSpanID, seen.SpanID)
+	assert.Equal(t, "congo=t61rcWkgMzE", seen.TraceState)
+	assert.Equal(t, seen.Traceparent(), rec.Header().Get(TraceresponseHeader))
+
+	spans := tracer.Spans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, seen, spans[0].SpanContext)
+	assert.Equal(t, http.StatusOK, spans[0].Status)
+	assert.Equal(t, "GET /test", spans[0].Name)
+}
+
+func TestTraceMiddleware_StartsNewTrace(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+	}{
+		{"missing header", ""},
+		{"invalid header", "not-a-traceparent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen SpanContext
+			h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				seen, _ = SpanContextFromContext(r.Context())
+				return nil
+			}).WithMiddlewares(TraceMiddleware())
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set(TraceparentHeader, tt.traceparent)
+			req.Header.Set(TracestateHeader, "congo=t61rcWkgMzE")
+
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.True(t, seen.IsValid())
+			assert.False(t, seen.ParentSpanID.IsValid())
+			assert.Empty(t, seen.TraceState)
+		})
+	}
+}
+
+func TestTraceMiddleware_StatusFromError(t *testing.T) {
+	tracer := NewRecordingTracer()
+
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return NewError(http.StatusConflict, "conflict")
+	}).WithMiddlewares(TraceMiddleware(WithTracer(tracer)))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+
+	spans := tracer.Spans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, http.StatusConflict, spans[0].Status)
+}
+
+func TestTraceMiddleware_Logs(t *testing.T) {
+	logs := captureLogs(t)
+
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return NewError(http.StatusInternalServerError, "boom", WithLog())
+	}).WithMiddlewares(TraceMiddleware())
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, logs.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=")
+}
@@ -0,0 +1,115 @@
+package httpbox
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type urlParamKind int
+
+const (
+	urlParamPath urlParamKind = iota
+	urlParamQuery
+)
+
+type URLParam struct {
+	kind  urlParamKind
+	name  string
+	value string
+}
+
+// URLPathValue fills the path wildcard {name} or {name...} of a route
+func URLPathValue(name, value string) URLParam {
+	return URLParam{kind: urlParamPath, name: name, value: value}
+}
+
+func URLQueryValue(name, value string) URLParam {
+	return URLParam{kind: urlParamQuery, name: name, value: value}
+}
+
+// URLFor builds the path of the route with the given name, escaping wildcard
+// values and appending query values. Every wildcard must be given a value and
+// every path value must match a wildcard
+func (rt *Router) URLFor(name string, params ...URLParam) (string, error) {
+	rt.tree.mu.RLock()
+	route, ok := rt.tree.names[name]
+	rt.tree.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("httpbox: unknown route name %q", name)
+	}
+
+	pathValues := make(map[string]string)
+	query := make(url.Values)
+
+	for _, param := range params {
+		switch param.kind {
+		case urlParamPath:
+			pathValues[param.name] = param.value
+		case urlParamQuery:
+			query.Add(param.name, param.value)
+		}
+	}
+
+	path, err := buildPath(route.Path, pathValues)
+	if err != nil {
+		return "", fmt.Errorf("httpbox: route %q: %w", name, err)
+	}
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	return path, nil
+}
+
+func buildPath(pattern string, values map[string]string) (string, error) {
+	segments := strings.Split(pattern, "/")
+	used := make(map[string]bool)
+
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+
+		wildcard := segment[1 : len(segment)-1]
+
+		if wildcard == "$" {
+			segments[i] = ""
+			continue
+		}
+
+		name, multi := strings.CutSuffix(wildcard, "...")
+
+		value, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf("missing value for path wildcard %q", name)
+		}
+		used[name] = true
+
+		if !multi {
+			// ServeMux never matches an empty segment for a single wildcard
+			if value == "" {
+				return "", fmt.Errorf("empty value for path wildcard %q", name)
+			}
+
+			segments[i] = url.PathEscape(value)
+			continue
+		}
+
+		parts := strings.Split(value, "/")
+		for j, part := range parts {
+			parts[j] = url.PathEscape(part)
+		}
+		segments[i] = strings.Join(parts, "/")
+	}
+
+	for name := range values {
+		if !used[name] {
+			return "", fmt.Errorf("unknown path wildcard %q", name)
+		}
+	}
+
+	return strings.Join(segments, "/"), nil
+}
@@ -0,0 +1,103 @@
+package httpbox
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newURLTestRouter() *Router {
+	rt := NewRouter()
+	rt.Handle("GET /{$}", textHandler("home")).Named("home")
+
+	api := rt.Group("/api")
+	api.Handle("GET /users/{id}", textHandler("user")).Named("user")
+	api.Handle("GET /users/{id}/posts/{post}", textHandler("post")).Named("post")
+	api.Handle("GET /files/{path...}", textHandler("file")).Named("file")
+	api.Handle("GET /dirs/{$}", textHandler("dirs")).Named("dirs")
+
+	return rt
+}
+
+func TestRouter_URLFor(t *testing.T) {
+	rt := newURLTestRouter()
+
+	tests := []struct {
+		name     string
+		route    string
+		params   []URLParam
+		expected string
+	}{
+		{"static route", "home", nil, "/"},
+		{"single wildcard", "user", []URLParam{URLPathValue("id", "42")}, "/api/users/42"},
+		{"escaped value", "user", []URLParam{URLPathValue("id", "a/b c?")}, "/api/users/a%2Fb%20c%3F"},
+		{"several wildcards", "post", []URLParam{URLPathValue("post", "7"), URLPathValue("id", "1")}, "/api/users/1/posts/7"},
+		{"rest wildcard keeps slashes", "file", []URLParam{URLPathValue("path", "docs/read me.md")}, "/api/files/docs/read%20me.md"},
+		{"empty rest wildcard", "file", []URLParam{URLPathValue("path", "")}, "/api/files/"},
+		{"end anchor", "dirs", nil, "/api/dirs/"},
+		{"query values", "user", []URLParam{
+			URLPathValue("id", "42"),
+			URLQueryValue("tab", "posts"),
+			URLQueryValue("q", "a&b"),
+		}, "/api/users/42?q=a%26b&tab=posts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, err := rt.URLFor(tt.route, tt.params...)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, url)
+		})
+	}
+}
+
+func TestRouter_URLFor_Errors(t *testing.T) {
+	rt := newURLTestRouter()
+
+	tests := []struct {
+		name   string
+		route  string
+		params []URLParam
+		err    string
+	}{
+		{"unknown route", "missing", nil, `unknown route name "missing"`},
+		{"missing wildcard", "post", []URLParam{URLPathValue("id", "1")}, `missing value for path wildcard "post"`},
+		{"unknown wildcard", "user", []URLParam{URLPathValue("id", "1"), URLPathValue("slug", "x")}, `unknown path wildcard "slug"`},
+		{"empty wildcard", "user", []URLParam{URLPathValue("id", "")}, `empty value for path wildcard "id"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := rt.URLFor(tt.route, tt.params...)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestRouter_URLFor_MatchesRoute(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /files/{path...}", func(w http.ResponseWriter, r *http.Request) error {
+		return WriteBytes(w, http.StatusOK, "text/plain", []byte(r.PathValue("path")))
+	}).Named("file")
+
+	url, err := rt.URLFor("file", URLPathValue("path", "a b/c%d"))
+	require.NoError(t, err)
+
+	rec := serveRouter(rt, http.MethodGet, url)
+
+	assert.Equal(t, "a b/c%d", rec.Body.String())
+}
+
+func TestRoute_NamedDuplicate(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET /a", textHandler("a")).Named("page")
+	route := rt.Handle("GET /b", textHandler("b"))
+
+	assert.Panics(t, func() { route.Named("page") })
+	assert.Equal(t, "page", rt.Routes()[0].Name)
+}